@@ -0,0 +1,132 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/fabiante/podbouncer/internal/archive"
+	"github.com/fabiante/podbouncer/internal/controller"
+)
+
+// archiveOptions configure archiving the logs and snapshots of pods before they are deleted.
+type archiveOptions struct {
+	Dir        string
+	S3Endpoint string
+	S3Bucket   string
+	S3Region   string
+	Phases     string
+	Attempts   int
+	Logs       bool
+	Snapshots  bool
+}
+
+// bindArchiveFlags registers the flags configuring the archive.
+func bindArchiveFlags(fs *flag.FlagSet, o *archiveOptions) {
+	bindArchiveBackendFlags(fs, o)
+	fs.StringVar(&o.Phases, "log-archive-phases", string(v1.PodFailed),
+		"Comma separated list of pod phases whose logs are archived. Use an empty value to archive all pods.")
+	fs.IntVar(&o.Attempts, "log-archive-max-attempts", 10,
+		"How often retrieving the logs of a pod is attempted before it is deleted without them. "+
+			"Use 0 to retry until the logs are retrieved.")
+	fs.BoolVar(&o.Logs, "archive-logs", true,
+		"If set, the container logs of pods are archived before the pods are deleted.")
+	fs.BoolVar(&o.Snapshots, "archive-snapshots", false,
+		"If set, the pod object and its recent events are archived before the pod is deleted.")
+}
+
+// bindArchiveBackendFlags registers the flags configuring where the archive is stored.
+func bindArchiveBackendFlags(fs *flag.FlagSet, o *archiveOptions) {
+	fs.StringVar(&o.Dir, "log-archive-dir", "",
+		"If set, the archive is stored below this directory.")
+	fs.StringVar(&o.S3Endpoint, "log-archive-s3-endpoint", "",
+		"If set, the archive is stored in an S3 compatible object storage at this URL. "+
+			"Credentials are read from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.")
+	fs.StringVar(&o.S3Bucket, "log-archive-s3-bucket", "", "The bucket the archive is stored in.")
+	fs.StringVar(&o.S3Region, "log-archive-s3-region", "us-east-1", "The region of the archive bucket.")
+}
+
+// backend creates the archive backend described by the options. It returns nil if
+// no backend is configured.
+func (o archiveOptions) backend() (archive.Backend, error) {
+	switch {
+	case o.Dir != "" && o.S3Endpoint != "":
+		return nil, errors.New("--log-archive-dir cannot be combined with --log-archive-s3-endpoint")
+	case o.Dir != "":
+		return &archive.DirBackend{Root: o.Dir}, nil
+	case o.S3Endpoint != "":
+		if o.S3Bucket == "" {
+			return nil, errors.New("--log-archive-s3-bucket is required")
+		}
+		return &archive.S3Backend{
+			Endpoint:        o.S3Endpoint,
+			Bucket:          o.S3Bucket,
+			Region:          o.S3Region,
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// hooks creates the snapshotter and log archiver described by the options. It returns
+// no hooks if archiving is disabled.
+func (o archiveOptions) hooks(cfg *rest.Config) ([]controller.PreDeleteHook, error) {
+	backend, err := o.backend()
+	if err != nil || backend == nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset: %w", err)
+	}
+
+	var hooks []controller.PreDeleteHook
+	if o.Snapshots {
+		hooks = append(hooks, &archive.Snapshotter{
+			Client:  clientset.CoreV1(),
+			Backend: backend,
+		})
+	}
+	if o.Logs {
+		var phases []v1.PodPhase
+		for _, phase := range strings.Split(o.Phases, ",") {
+			if phase = strings.TrimSpace(phase); phase != "" {
+				phases = append(phases, v1.PodPhase(phase))
+			}
+		}
+
+		hooks = append(hooks, &archive.LogArchiver{
+			Pods:        clientset.CoreV1(),
+			Backend:     backend,
+			Phases:      phases,
+			MaxAttempts: o.Attempts,
+		})
+	}
+
+	return hooks, nil
+}
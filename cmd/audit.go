@@ -0,0 +1,78 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/utils/clock"
+
+	"github.com/fabiante/podbouncer/internal/audit"
+	"github.com/fabiante/podbouncer/internal/controller"
+)
+
+// auditOptions configure the audit log of pod deletion decisions.
+type auditOptions struct {
+	Path       string
+	MaxSize    int64
+	MaxBackups int
+}
+
+// bindAuditFlags registers the flags configuring the audit log.
+func bindAuditFlags(fs *flag.FlagSet, o *auditOptions) {
+	fs.StringVar(&o.Path, "audit-log", "",
+		"If set, a JSON record of every pod deletion decision is appended to this file. Use \"-\" to write to stdout.")
+	fs.Int64Var(&o.MaxSize, "audit-log-max-size", 100,
+		"The size in megabytes after which the audit log file is rotated.")
+	fs.IntVar(&o.MaxBackups, "audit-log-max-backups", 5,
+		"The number of rotated audit log files which are kept.")
+}
+
+// observers creates the audit logger described by the options. It returns no observers
+// if the audit log is disabled. The returned closer must be called once the observers
+// are no longer used.
+func (o auditOptions) observers(actor string) ([]controller.DeletionObserver, io.Closer, error) {
+	switch o.Path {
+	case "":
+		return nil, nopCloser{}, nil
+	case "-":
+		return []controller.DeletionObserver{audit.NewLogger(os.Stdout, actor, clock.RealClock{})}, nopCloser{}, nil
+	}
+
+	f, err := audit.OpenRotatingFile(o.Path, o.MaxSize*1024*1024, o.MaxBackups)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	return []controller.DeletionObserver{audit.NewLogger(f, actor, clock.RealClock{})}, f, nil
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
+
+// auditActor identifies this podbouncer instance in audit records.
+func auditActor(mode string) string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return "podbouncer-" + mode + "@" + hostname
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+
+	"k8s.io/utils/clock"
+
+	"github.com/fabiante/podbouncer/internal/cloudevents"
+)
+
+// bindCloudEventsFlags registers the flags configuring the CloudEvents emission.
+func bindCloudEventsFlags(fs *flag.FlagSet, o *cloudevents.Options) {
+	*o = cloudevents.DefaultOptions()
+	fs.StringVar(&o.Sink, "cloudevents-sink", "",
+		"If set, CloudEvents about deleted pods, pods scheduled for deletion and configuration updates are posted to this URL.")
+	fs.StringVar(&o.Source, "cloudevents-source", o.Source, "The source attribute of all emitted CloudEvents.")
+	fs.IntVar(&o.QueueSize, "cloudevents-queue-size", o.QueueSize,
+		"The maximum number of CloudEvents waiting to be sent. Further events are dropped while the queue is full.")
+	fs.IntVar(&o.MaxAttempts, "cloudevents-max-attempts", o.MaxAttempts,
+		"The number of attempts to deliver a CloudEvent.")
+}
+
+// newEmitter creates the emitter described by the options. It returns nil if no
+// sink is configured.
+func newEmitter(o cloudevents.Options) *cloudevents.Emitter {
+	if o.Sink == "" {
+		return nil
+	}
+	return cloudevents.NewEmitter(o, clock.RealClock{})
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command kubectl-podbouncer is a kubectl plugin to inspect the decisions of podbouncer.
+//
+// Install it by placing the binary on your PATH, then run "kubectl podbouncer preview".
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `Usage: kubectl podbouncer <command> [flags]
+
+Commands:
+  preview   List the pods podbouncer will delete and when
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "preview":
+		os.Exit(runPreview(os.Args[2:], os.Stdout))
+	case "-h", "--help", "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
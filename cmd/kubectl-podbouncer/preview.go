@@ -0,0 +1,285 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/duration"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/fabiante/podbouncer/internal/controller"
+)
+
+// previewItem describes a pod which podbouncer will delete.
+type previewItem struct {
+	Namespace  string          `json:"namespace"`
+	Name       string          `json:"name"`
+	Phase      v1.PodPhase     `json:"phase"`
+	Age        metav1.Duration `json:"age"`
+	Rule       string          `json:"rule"`
+	ExpiresAt  time.Time       `json:"expiresAt"`
+	DeletionIn metav1.Duration `json:"deletionIn"`
+	Paused     bool            `json:"paused,omitempty"` // Not deleted until podbouncer is resumed
+}
+
+// runPreview implements "kubectl podbouncer preview". It evaluates the live pods with
+// the same policy as the controller and prints the pods which will be deleted.
+func runPreview(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("preview", flag.ContinueOnError)
+
+	var kubeconfig string
+	var kubeContext string
+	var namespace string
+	var allNamespaces bool
+	var selector string
+	var output string
+	var configFile string
+	var guardrails controller.ConfigGuardrails
+	fs.StringVar(&kubeconfig, "kubeconfig", "", "Path to the kubeconfig file.")
+	fs.StringVar(&kubeContext, "context", "", "The kubeconfig context to use.")
+	fs.StringVar(&namespace, "namespace", "", "Only list pods of this namespace. Defaults to the namespace of the context.")
+	fs.StringVar(&namespace, "n", "", "Shorthand for --namespace.")
+	fs.BoolVar(&allNamespaces, "all-namespaces", false, "List pods of all namespaces.")
+	fs.BoolVar(&allNamespaces, "A", false, "Shorthand for --all-namespaces.")
+	fs.StringVar(&selector, "selector", "", "Only list pods matching this label selector.")
+	fs.StringVar(&selector, "l", "", "Shorthand for --selector.")
+	fs.StringVar(&output, "output", "table", "The output format: table, json or yaml.")
+	fs.StringVar(&output, "o", "table", "Shorthand for --output.")
+	fs.StringVar(&configFile, "config-file", "",
+		"If set, the configuration is loaded from this file instead of from the podbouncer-config ConfigMap.")
+	bindGuardrailFlags(fs, &guardrails)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	if output != "table" && output != "json" && output != "yaml" {
+		fmt.Fprintf(os.Stderr, "unsupported output format %q\n", output)
+		return 2
+	}
+
+	if err := guardrails.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid guardrails: %s\n", err)
+		return 2
+	}
+
+	if err := preview(context.Background(), out, previewOptions{
+		kubeconfig:    kubeconfig,
+		kubeContext:   kubeContext,
+		namespace:     namespace,
+		allNamespaces: allNamespaces,
+		selector:      selector,
+		output:        output,
+		configFile:    configFile,
+		guardrails:    guardrails,
+	}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+type previewOptions struct {
+	kubeconfig    string
+	kubeContext   string
+	namespace     string
+	allNamespaces bool
+	selector      string
+	output        string
+	configFile    string
+	guardrails    controller.ConfigGuardrails
+}
+
+func preview(ctx context.Context, out io.Writer, opts previewOptions) error {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = opts.kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules,
+		&clientcmd.ConfigOverrides{CurrentContext: opts.kubeContext})
+
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+
+	c, err := client.New(restConfig, client.Options{Scheme: clientgoscheme.Scheme})
+	if err != nil {
+		return fmt.Errorf("failed to create client: %w", err)
+	}
+
+	config, err := loadPreviewConfig(ctx, c, opts)
+	if err != nil {
+		return err
+	}
+
+	var listOpts []client.ListOption
+	if !opts.allNamespaces {
+		namespace := opts.namespace
+		if namespace == "" {
+			if namespace, _, err = clientConfig.Namespace(); err != nil {
+				return fmt.Errorf("failed to determine namespace: %w", err)
+			}
+		}
+		listOpts = append(listOpts, client.InNamespace(namespace))
+	}
+	if opts.selector != "" {
+		sel, err := labels.Parse(opts.selector)
+		if err != nil {
+			return fmt.Errorf("invalid selector: %w", err)
+		}
+		listOpts = append(listOpts, client.MatchingLabelsSelector{Selector: sel})
+	}
+
+	var pods v1.PodList
+	if err := c.List(ctx, &pods, listOpts...); err != nil {
+		return fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	items := previewItems(pods.Items, config, time.Now())
+
+	switch opts.output {
+	case "json":
+		return printJSON(out, items)
+	case "yaml":
+		return printYAML(out, items)
+	default:
+		return printTable(out, items)
+	}
+}
+
+// loadPreviewConfig loads the configuration from the file or ConfigMap. A configuration
+// rejected by the guardrails is reported as error, since the controller would not apply it.
+func loadPreviewConfig(ctx context.Context, c client.Reader, opts previewOptions) (*controller.PodReconcilerConfig, error) {
+	config := controller.NewPodReconcilerConfig()
+
+	var err error
+	if opts.configFile != "" {
+		err = (&controller.FileConfigSource{Path: opts.configFile, Config: config, Guardrails: opts.guardrails}).Load()
+	} else {
+		err = controller.LoadConfigMap(ctx, c, config, opts.guardrails)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	return config, nil
+}
+
+// previewItems evaluates pods and returns the candidates ordered by their deletion time.
+// The deletion time respects the maintenance windows of config. While config is paused,
+// all items are marked as paused.
+func previewItems(pods []v1.Pod, config *controller.PodReconcilerConfig, now time.Time) []previewItem {
+	items := make([]previewItem, 0, len(pods))
+	paused := config.Paused()
+
+	for i := range pods {
+		pod := &pods[i]
+
+		decision, err := controller.EvaluatePod(pod, config, now)
+		if err != nil || !decision.Candidate {
+			continue
+		}
+
+		deletionIn := controller.DeletionTime(decision, config, now).Sub(now)
+
+		items = append(items, previewItem{
+			Namespace:  pod.Namespace,
+			Name:       pod.Name,
+			Phase:      pod.Status.Phase,
+			Age:        metav1.Duration{Duration: decision.Age.Round(time.Second)},
+			Rule:       decision.Rule,
+			ExpiresAt:  decision.ExpiresAt,
+			DeletionIn: metav1.Duration{Duration: deletionIn.Round(time.Second)},
+			Paused:     paused,
+		})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].DeletionIn != items[j].DeletionIn {
+			return items[i].DeletionIn.Duration < items[j].DeletionIn.Duration
+		}
+		return items[i].ExpiresAt.Before(items[j].ExpiresAt)
+	})
+
+	return items
+}
+
+// bindGuardrailFlags registers the flags configuring the guardrails with the same names
+// and defaults as the controller.
+func bindGuardrailFlags(fs *flag.FlagSet, g *controller.ConfigGuardrails) {
+	fs.DurationVar(&g.Floor, "max-pod-age-floor", time.Minute,
+		"The smallest maxPodAge which is accepted from the configuration. "+
+			"Lower values are only applied if the configuration sets allowAggressive to \"true\". "+
+			"Must match the flag of the controller.")
+	fs.DurationVar(&g.Ceiling, "max-pod-age-ceiling", 0,
+		"The largest maxPodAge which is accepted from the configuration. Use 0 to disable the ceiling. "+
+			"Must match the flag of the controller.")
+}
+
+func printTable(out io.Writer, items []previewItem) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tPHASE\tAGE\tRULE\tDELETION IN")
+
+	for _, item := range items {
+		deletionIn := "now"
+		switch {
+		case item.Paused:
+			deletionIn = "paused"
+		case item.DeletionIn.Duration > 0:
+			deletionIn = duration.HumanDuration(item.DeletionIn.Duration)
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", item.Namespace, item.Name, item.Phase,
+			duration.HumanDuration(item.Age.Duration), item.Rule, deletionIn)
+	}
+
+	return w.Flush()
+}
+
+func printJSON(out io.Writer, items []previewItem) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(items)
+}
+
+func printYAML(out io.Writer, items []previewItem) error {
+	data, err := yaml.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	_, err = out.Write(data)
+	return err
+}
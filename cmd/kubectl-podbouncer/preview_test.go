@@ -0,0 +1,279 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/fabiante/podbouncer/internal/controller"
+)
+
+func Test_PreviewItems(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	pod := func(name string, phase v1.PodPhase, age time.Duration) v1.Pod {
+		return v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "default",
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			},
+			Status: v1.PodStatus{Phase: phase},
+		}
+	}
+
+	kept := pod("kept", v1.PodFailed, 2*time.Hour)
+	kept.Annotations = map[string]string{controller.KeepAnnotation: "true"}
+
+	pods := []v1.Pod{
+		pod("later", v1.PodPending, 10*time.Minute),
+		pod("also-expired", v1.PodFailed, 90*time.Minute),
+		pod("running", v1.PodRunning, 2*time.Hour),
+		kept,
+		pod("soon", v1.PodSucceeded, 50*time.Minute),
+		pod("expired", v1.PodFailed, 2*time.Hour),
+	}
+
+	windows, err := controller.ParseMaintenanceWindows("0 13 * * * 1h", "")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		windows    *controller.MaintenanceWindows
+		paused     bool
+		names      []string
+		deletionIn []time.Duration
+	}{
+		{
+			name:       "orders by deletion time and clamps expired pods at 0",
+			names:      []string{"expired", "also-expired", "soon", "later"},
+			deletionIn: []time.Duration{0, 0, 10 * time.Minute, 50 * time.Minute},
+		},
+		{
+			name:       "defers deletions to the next maintenance window",
+			windows:    windows,
+			names:      []string{"expired", "also-expired", "soon", "later"},
+			deletionIn: []time.Duration{time.Hour, time.Hour, time.Hour, time.Hour},
+		},
+		{
+			name:       "marks all pods while paused",
+			paused:     true,
+			names:      []string{"expired", "also-expired", "soon", "later"},
+			deletionIn: []time.Duration{0, 0, 10 * time.Minute, 50 * time.Minute},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := controller.NewPodReconcilerConfig()
+			config.SetMaintenanceWindows(tt.windows)
+			config.SetPaused(tt.paused)
+
+			items := previewItems(pods, config, now)
+			for _, item := range items {
+				require.Equal(t, tt.paused, item.Paused, item.Name)
+			}
+
+			var names []string
+			var deletionIn []time.Duration
+			for _, item := range items {
+				names = append(names, item.Name)
+				deletionIn = append(deletionIn, item.DeletionIn.Duration)
+			}
+			require.Equal(t, tt.names, names)
+			require.Equal(t, tt.deletionIn, deletionIn)
+		})
+	}
+}
+
+func Test_PreviewOutput(t *testing.T) {
+	items := []previewItem{
+		{
+			Namespace:  "default",
+			Name:       "expired",
+			Phase:      v1.PodFailed,
+			Age:        metav1.Duration{Duration: 2 * time.Hour},
+			Rule:       "phase=Failed",
+			ExpiresAt:  time.Date(2026, 10, 16, 11, 0, 0, 0, time.UTC),
+			DeletionIn: metav1.Duration{},
+		},
+		{
+			Namespace:  "default",
+			Name:       "soon",
+			Phase:      v1.PodSucceeded,
+			Age:        metav1.Duration{Duration: 50 * time.Minute},
+			Rule:       "phase=Succeeded",
+			ExpiresAt:  time.Date(2026, 10, 16, 12, 10, 0, 0, time.UTC),
+			DeletionIn: metav1.Duration{Duration: 10 * time.Minute},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		print func(*bytes.Buffer, []previewItem) error
+		want  string
+	}{
+		{
+			name:  "table",
+			print: func(b *bytes.Buffer, items []previewItem) error { return printTable(b, items) },
+			want: `NAMESPACE   NAME      PHASE       AGE    RULE              DELETION IN
+default     expired   Failed      120m   phase=Failed      now
+default     soon      Succeeded   50m    phase=Succeeded   10m
+`,
+		},
+		{
+			name:  "json",
+			print: func(b *bytes.Buffer, items []previewItem) error { return printJSON(b, items) },
+			want: `[
+  {
+    "namespace": "default",
+    "name": "expired",
+    "phase": "Failed",
+    "age": "2h0m0s",
+    "rule": "phase=Failed",
+    "expiresAt": "2026-10-16T11:00:00Z",
+    "deletionIn": "0s"
+  },
+  {
+    "namespace": "default",
+    "name": "soon",
+    "phase": "Succeeded",
+    "age": "50m0s",
+    "rule": "phase=Succeeded",
+    "expiresAt": "2026-10-16T12:10:00Z",
+    "deletionIn": "10m0s"
+  }
+]
+`,
+		},
+		{
+			name:  "yaml",
+			print: func(b *bytes.Buffer, items []previewItem) error { return printYAML(b, items) },
+			want: `- age: 2h0m0s
+  deletionIn: 0s
+  expiresAt: "2026-10-16T11:00:00Z"
+  name: expired
+  namespace: default
+  phase: Failed
+  rule: phase=Failed
+- age: 50m0s
+  deletionIn: 10m0s
+  expiresAt: "2026-10-16T12:10:00Z"
+  name: soon
+  namespace: default
+  phase: Succeeded
+  rule: phase=Succeeded
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			require.NoError(t, tt.print(&out, items))
+			require.Equal(t, tt.want, out.String())
+		})
+	}
+}
+
+func Test_PreviewOutputPaused(t *testing.T) {
+	items := []previewItem{{
+		Namespace: "default",
+		Name:      "expired",
+		Phase:     v1.PodFailed,
+		Age:       metav1.Duration{Duration: 2 * time.Hour},
+		Rule:      "phase=Failed",
+		ExpiresAt: time.Date(2026, 10, 16, 11, 0, 0, 0, time.UTC),
+		Paused:    true,
+	}}
+
+	var table bytes.Buffer
+	require.NoError(t, printTable(&table, items))
+	require.Equal(t, `NAMESPACE   NAME      PHASE    AGE    RULE           DELETION IN
+default     expired   Failed   120m   phase=Failed   paused
+`, table.String())
+
+	var json bytes.Buffer
+	require.NoError(t, printJSON(&json, items))
+	require.Contains(t, json.String(), `"paused": true`)
+}
+
+func Test_LoadPreviewConfig(t *testing.T) {
+	guardrails := controller.ConfigGuardrails{Floor: time.Minute}
+
+	configMap := func(data map[string]string) *v1.ConfigMap {
+		return &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "podbouncer-system", Name: "podbouncer-config"},
+			Data:       data,
+		}
+	}
+
+	tests := []struct {
+		name      string
+		data      map[string]string
+		maxPodAge time.Duration
+		wantErr   string
+	}{
+		{
+			name:      "accepted",
+			data:      map[string]string{"maxPodAge": "2h"},
+			maxPodAge: 2 * time.Hour,
+		},
+		{
+			name:    "below the floor",
+			data:    map[string]string{"maxPodAge": "10s"},
+			wantErr: "below the floor",
+		},
+		{
+			name:      "below the floor with allowAggressive",
+			data:      map[string]string{"maxPodAge": "10s", "allowAggressive": "true"},
+			maxPodAge: 10 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithObjects(configMap(tt.data)).Build()
+
+			config, err := loadPreviewConfig(context.Background(), c, previewOptions{guardrails: guardrails})
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.maxPodAge, config.MaxPodAge())
+		})
+	}
+
+	t.Run("config file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("maxPodAge: 10s\n"), 0o600))
+
+		_, err := loadPreviewConfig(context.Background(), nil, previewOptions{configFile: path, guardrails: guardrails})
+		require.ErrorContains(t, err, "below the floor")
+	})
+}
@@ -17,24 +17,34 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
+	"github.com/fabiante/podbouncer/internal/cloudevents"
 	"github.com/fabiante/podbouncer/internal/controller"
 	// +kubebuilder:scaffold:imports
 )
@@ -44,6 +54,9 @@ var (
 	setupLog = ctrl.Log.WithName("setup")
 )
 
+// configMapLoadTimeout bounds loading the ConfigMap on startup.
+const configMapLoadTimeout = 30 * time.Second
+
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
@@ -51,12 +64,52 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "sweep":
+			os.Exit(runSweep(os.Args[2:]))
+		case "simulate":
+			os.Exit(runSimulate(os.Args[2:], os.Stdout))
+		case "snapshot":
+			os.Exit(runSnapshot(os.Args[2:], os.Stdout))
+		}
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
 	var tlsOpts []func(*tls.Config)
+	var guardrails controller.ConfigGuardrails
+	var configFile string
+	var mode string
+	var sweepInterval time.Duration
+	var sweepConcurrency int
+	var annotateDeleteAfter bool
+	var warningLeadTime time.Duration
+	var finalizerTimeout time.Duration
+	var staleFinalizers string
+	var deleteFinishedJobs bool
+	var recreatePolicy string
+	var recreateBackoff time.Duration
+	var recreateMaxBackoff time.Duration
+	var churnWindow time.Duration
+	var churnThreshold int
+	var stopOnChurn bool
+	var paused bool
+	var unreadyWhenPaused bool
+	var enableSharding bool
+	var shardIdentity string
+	var shardLeaseNamespace string
+	var shardLeaseDuration time.Duration
+	var shardRenewInterval time.Duration
+	var auditOpts auditOptions
+	var archiveOpts archiveOptions
+	var notifyOpts notifyOptions
+	var cloudEventsOpts cloudevents.Options
+	podReconcilerOptions := controller.DefaultReconcilerOptions()
+	configMapReconcilerOptions := controller.DefaultReconcilerOptions()
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -67,6 +120,64 @@ func main() {
 		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&configFile, "config-file", "",
+		"If set, the configuration is loaded from this file and reloaded on changes "+
+			"instead of from the podbouncer-config ConfigMap.")
+	flag.StringVar(&mode, "mode", "event",
+		"How pods are processed. Use \"event\" to reconcile each pod on changes or \"sweep\" to "+
+			"evaluate all pods in periodic batches.")
+	flag.DurationVar(&sweepInterval, "sweep-interval", time.Minute, "The time between two sweeps in sweep mode.")
+	flag.IntVar(&sweepConcurrency, "sweep-concurrency", 10, "The maximum number of concurrent deletions in sweep mode.")
+	flag.BoolVar(&annotateDeleteAfter, "annotate-delete-after", true,
+		"If set, pods waiting to reach their max age are annotated with "+controller.DeleteAfterAnnotation+
+			" in event mode.")
+	flag.DurationVar(&warningLeadTime, "deletion-warning-lead-time", 10*time.Minute,
+		"The time before deletion at which a warning event is emitted on the pod in event mode. Use 0 to disable warnings.")
+	flag.DurationVar(&finalizerTimeout, "finalizer-timeout", 10*time.Minute,
+		"The time after which deleted pods which are kept by their finalizers are counted as stuck in event mode.")
+	flag.StringVar(&staleFinalizers, "stale-finalizers", "",
+		"Comma separated list of finalizers which are removed from pods stuck for --finalizer-timeout in event mode.")
+	flag.BoolVar(&deleteFinishedJobs, "delete-finished-jobs", false,
+		"If set, the finished Job controlling an expired pod is deleted instead of the pod, "+
+			"unless the Job sets ttlSecondsAfterFinished.")
+	flag.StringVar(&recreatePolicy, "recreate-policy", string(controller.RecreatePolicyDelete),
+		"How expired Pending pods are handled whose ReplicaSet, StatefulSet, DaemonSet or ReplicationController "+
+			"would recreate them: delete, skip, report or backoff. Only applies in event mode, "+
+			"sweep mode deletes such pods like any other pod.")
+	flag.DurationVar(&recreateBackoff, "recreate-backoff", time.Minute,
+		"The initial delay between two deletions of pods of the same owner with --recreate-policy=backoff.")
+	flag.DurationVar(&recreateMaxBackoff, "recreate-max-backoff", time.Hour,
+		"The maximum delay between two deletions of pods of the same owner with --recreate-policy=backoff.")
+	flag.DurationVar(&churnWindow, "churn-window", time.Hour,
+		"The sliding window in which deletions of pods of the same owner are counted in event mode. "+
+			"Set to 0 to disable churn detection.")
+	flag.IntVar(&churnThreshold, "churn-threshold", 10,
+		"The number of deleted pods of the same owner within --churn-window from which a warning event "+
+			"is emitted on the owner. Set to 0 to disable churn detection.")
+	flag.BoolVar(&stopOnChurn, "stop-on-churn", false,
+		"If set, pods of an owner are not deleted while it is above --churn-threshold.")
+	flag.BoolVar(&paused, "paused", false,
+		"If set, no pods are deleted and no finalizers are removed, regardless of the paused configuration key.")
+	flag.BoolVar(&unreadyWhenPaused, "unready-when-paused", false,
+		"If set, the readiness probe fails while podbouncer is paused.")
+	bindGuardrailFlags(flag.CommandLine, &guardrails)
+	flag.BoolVar(&enableSharding, "shard", false,
+		"If set, all replicas are active and each one processes a share of the namespaces. "+
+			"Cannot be combined with --leader-elect.")
+	flag.StringVar(&shardIdentity, "shard-identity", "",
+		"The unique identity of this replica in the shard ring. Defaults to the hostname.")
+	flag.StringVar(&shardLeaseNamespace, "shard-lease-namespace", "podbouncer-system",
+		"The namespace of the Leases used to coordinate shard members.")
+	flag.DurationVar(&shardLeaseDuration, "shard-lease-duration", 15*time.Second,
+		"The time after which a replica which stopped renewing its Lease is removed from the shard ring.")
+	flag.DurationVar(&shardRenewInterval, "shard-renew-interval", 5*time.Second,
+		"The time between two renewals of the Lease of this replica.")
+	bindAuditFlags(flag.CommandLine, &auditOpts)
+	bindArchiveFlags(flag.CommandLine, &archiveOpts)
+	bindNotifyFlags(flag.CommandLine, &notifyOpts)
+	bindCloudEventsFlags(flag.CommandLine, &cloudEventsOpts)
+	bindReconcilerFlags(flag.CommandLine, "pod", &podReconcilerOptions)
+	bindReconcilerFlags(flag.CommandLine, "configmap", &configMapReconcilerOptions)
 	opts := zap.Options{
 		Development: true,
 	}
@@ -75,6 +186,38 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	ctx := ctrl.SetupSignalHandler()
+
+	if enableSharding && enableLeaderElection {
+		setupLog.Error(nil, "--shard cannot be combined with --leader-elect")
+		os.Exit(1)
+	}
+
+	if enableSharding && shardIdentity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			setupLog.Error(err, "unable to determine shard identity")
+			os.Exit(1)
+		}
+		shardIdentity = hostname
+	}
+
+	if mode != "event" && mode != "sweep" {
+		setupLog.Error(nil, "mode must be either event or sweep", "mode", mode)
+		os.Exit(1)
+	}
+
+	if err := guardrails.Validate(); err != nil {
+		setupLog.Error(err, "invalid guardrails")
+		os.Exit(1)
+	}
+
+	parsedRecreatePolicy, err := controller.ParseRecreatePolicy(recreatePolicy)
+	if err != nil {
+		setupLog.Error(err, "invalid recreate policy")
+		os.Exit(1)
+	}
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancellation and
@@ -117,7 +260,13 @@ func main() {
 	}
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
+		Scheme: scheme,
+		Cache: cache.Options{
+			ByObject: map[client.Object]cache.ByObject{
+				&corev1.Pod{}:  controller.PodCacheOptions(),
+				&batchv1.Job{}: controller.JobCacheOptions(),
+			},
+		},
 		Metrics:                metricsServerOptions,
 		WebhookServer:          webhookServer,
 		HealthProbeBindAddress: probeAddr,
@@ -141,23 +290,155 @@ func main() {
 	}
 
 	podReconcilerConfig := controller.NewPodReconcilerConfig()
+	if paused {
+		setupLog.Info("Starting paused, no pods will be deleted")
+		podReconcilerConfig.ForcePause()
+	}
+	configChanges := make(chan event.GenericEvent, 1)
 
-	if err = (&controller.PodReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Config: podReconcilerConfig,
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "Pod")
+	var shard *controller.ShardCoordinator
+	if enableSharding {
+		shard = &controller.ShardCoordinator{
+			Client:        mgr.GetClient(),
+			Reader:        mgr.GetAPIReader(),
+			Namespace:     shardLeaseNamespace,
+			Identity:      shardIdentity,
+			LeaseDuration: shardLeaseDuration,
+			RenewInterval: shardRenewInterval,
+		}
+		if err = mgr.Add(shard); err != nil {
+			setupLog.Error(err, "unable to create shard coordinator")
+			os.Exit(1)
+		}
+	}
+
+	observers, auditLog, err := auditOpts.observers(auditActor(mode))
+	if err != nil {
+		setupLog.Error(err, "unable to create audit log")
 		os.Exit(1)
 	}
-	if err = (&controller.ConfigMapReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Config: podReconcilerConfig,
-	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "ConfigMap")
+	defer auditLog.Close()
+
+	notifier, err := notifyOpts.notifier()
+	if err != nil {
+		setupLog.Error(err, "unable to create notifier")
 		os.Exit(1)
 	}
+	if notifier != nil {
+		if err = mgr.Add(notifier); err != nil {
+			setupLog.Error(err, "unable to create notifier")
+			os.Exit(1)
+		}
+		observers = append(observers, notifier)
+	}
+
+	var scheduleObservers []controller.ScheduleObserver
+	var configObservers []controller.ConfigObserver
+	if emitter := newEmitter(cloudEventsOpts); emitter != nil {
+		if err = mgr.Add(emitter); err != nil {
+			setupLog.Error(err, "unable to create CloudEvents emitter")
+			os.Exit(1)
+		}
+		observers = append(observers, emitter)
+		scheduleObservers = append(scheduleObservers, emitter)
+		configObservers = append(configObservers, emitter)
+	}
+
+	hooks, err := archiveOpts.hooks(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create log archive")
+		os.Exit(1)
+	}
+
+	switch mode {
+	case "sweep":
+		if err = mgr.Add(&controller.PodSweeper{
+			Client:             mgr.GetClient(),
+			Config:             podReconcilerConfig,
+			Interval:           sweepInterval,
+			Concurrency:        sweepConcurrency,
+			Shard:              shard,
+			PreDeleteHooks:     hooks,
+			Observers:          observers,
+			DeleteFinishedJobs: deleteFinishedJobs,
+		}); err != nil {
+			setupLog.Error(err, "unable to create sweeper")
+			os.Exit(1)
+		}
+		// Changes are picked up by the next sweep
+		configChanges = nil
+	default:
+		if err = (&controller.PodReconciler{
+			Client:              mgr.GetClient(),
+			Scheme:              mgr.GetScheme(),
+			Config:              podReconcilerConfig,
+			ConfigChanges:       configChanges,
+			Options:             podReconcilerOptions,
+			Shard:               shard,
+			PreDeleteHooks:      hooks,
+			Observers:           observers,
+			ScheduleObservers:   scheduleObservers,
+			AnnotateDeleteAfter: annotateDeleteAfter,
+			WarningLeadTime:     warningLeadTime,
+			FinalizerTimeout:    finalizerTimeout,
+			StaleFinalizers:     splitList(staleFinalizers),
+			DeleteFinishedJobs:  deleteFinishedJobs,
+			RecreatePolicy:      parsedRecreatePolicy,
+			RecreateBackoff:     recreateBackoff,
+			RecreateMaxBackoff:  recreateMaxBackoff,
+			ChurnWindow:         churnWindow,
+			ChurnThreshold:      churnThreshold,
+			StopOnChurn:         stopOnChurn,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Pod")
+			os.Exit(1)
+		}
+	}
+	if configFile != "" {
+		configSource := &controller.FileConfigSource{
+			Path:       configFile,
+			Config:     podReconcilerConfig,
+			Guardrails: guardrails,
+			Changes:    configChanges,
+			Observers:  configObservers,
+		}
+		if err = configSource.Load(); err != nil {
+			setupLog.Error(err, "unable to load config file")
+			os.Exit(1)
+		}
+		if err = mgr.Add(configSource); err != nil {
+			setupLog.Error(err, "unable to watch config file")
+			os.Exit(1)
+		}
+	} else {
+		// Apply the ConfigMap before any pod is evaluated, the ConfigMapReconciler only
+		// catches up once the caches have synced
+		configMapReconciler := &controller.ConfigMapReconciler{
+			Client:     mgr.GetClient(),
+			Scheme:     mgr.GetScheme(),
+			Config:     podReconcilerConfig,
+			Guardrails: guardrails,
+			Changes:    configChanges,
+			Observers:  configObservers,
+			Options:    configMapReconcilerOptions,
+		}
+
+		loadCtx, cancel := context.WithTimeout(ctx, configMapLoadTimeout)
+		err = configMapReconciler.Load(loadCtx, mgr.GetAPIReader())
+		cancel()
+		switch {
+		case apierrors.IsNotFound(err):
+			setupLog.Info("ConfigMap not found, using the default configuration")
+		case err != nil:
+			setupLog.Error(err, "unable to load ConfigMap, pausing until it is applied")
+			podReconcilerConfig.PauseUntilApplied()
+		}
+
+		if err = configMapReconciler.SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ConfigMap")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -168,10 +449,50 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if unreadyWhenPaused {
+		if err := mgr.AddReadyzCheck("paused", controller.PausedChecker(podReconcilerConfig)); err != nil {
+			setupLog.Error(err, "unable to set up paused check")
+			os.Exit(1)
+		}
+	}
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
 }
+
+// splitList splits a comma separated flag value, ignoring empty elements.
+func splitList(value string) []string {
+	var list []string
+	for _, element := range strings.Split(value, ",") {
+		if element = strings.TrimSpace(element); element != "" {
+			list = append(list, element)
+		}
+	}
+	return list
+}
+
+// bindReconcilerFlags registers the flags configuring the throughput of the named controller.
+func bindReconcilerFlags(fs *flag.FlagSet, name string, o *controller.ReconcilerOptions) {
+	fs.IntVar(&o.MaxConcurrentReconciles, name+"-max-concurrent-reconciles", o.MaxConcurrentReconciles,
+		"The number of workers of the "+name+" controller.")
+	fs.DurationVar(&o.RateLimitBaseDelay, name+"-rate-limit-base-delay", o.RateLimitBaseDelay,
+		"The initial delay before retrying a failed reconciliation of the "+name+" controller.")
+	fs.DurationVar(&o.RateLimitMaxDelay, name+"-rate-limit-max-delay", o.RateLimitMaxDelay,
+		"The maximum delay before retrying a failed reconciliation of the "+name+" controller.")
+	fs.Float64Var(&o.RateLimitQPS, name+"-rate-limit-qps", o.RateLimitQPS,
+		"The overall rate of requests per second the "+name+" controller queues.")
+	fs.IntVar(&o.RateLimitBurst, name+"-rate-limit-burst", o.RateLimitBurst,
+		"The burst of requests the "+name+" controller queues above its rate limit.")
+}
+
+// bindGuardrailFlags registers the flags configuring the hard limits of configuration values.
+func bindGuardrailFlags(fs *flag.FlagSet, g *controller.ConfigGuardrails) {
+	fs.DurationVar(&g.Floor, "max-pod-age-floor", time.Minute,
+		"The smallest maxPodAge which is accepted from the configuration. "+
+			"Lower values are only applied if the configuration sets allowAggressive to \"true\".")
+	fs.DurationVar(&g.Ceiling, "max-pod-age-ceiling", 0,
+		"The largest maxPodAge which is accepted from the configuration. Use 0 to disable the ceiling.")
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/utils/clock"
+
+	"github.com/fabiante/podbouncer/internal/notify"
+)
+
+// notifyOptions configure the webhook notifications about deleted pods.
+type notifyOptions struct {
+	notify.Options
+	URLs       string
+	SecretFile string
+}
+
+// bindNotifyFlags registers the flags configuring the webhook notifications.
+func bindNotifyFlags(fs *flag.FlagSet, o *notifyOptions) {
+	o.Options = notify.DefaultOptions()
+	fs.StringVar(&o.URLs, "notify-url", "",
+		"Comma separated list of URLs which receive batches of deleted pods as JSON via POST.")
+	fs.StringVar(&o.SecretFile, "notify-secret-file", "",
+		"If set, notifications are signed with the secret read from this file. The signature is sent in the "+
+			notify.SignatureHeader+" header.")
+	fs.IntVar(&o.BatchSize, "notify-batch-size", o.BatchSize, "The maximum number of deleted pods per notification.")
+	fs.DurationVar(&o.FlushInterval, "notify-flush-interval", o.FlushInterval,
+		"The maximum time a deleted pod waits for its notification to be sent.")
+	fs.IntVar(&o.QueueSize, "notify-queue-size", o.QueueSize,
+		"The maximum number of deleted pods waiting to be sent. Further pods are dropped while the queue is full.")
+	fs.IntVar(&o.MaxAttempts, "notify-max-attempts", o.MaxAttempts,
+		"The number of attempts to deliver a notification to an endpoint.")
+}
+
+// notifier creates the notifier described by the options. It returns nil if no
+// URLs are configured.
+func (o notifyOptions) notifier() (*notify.Notifier, error) {
+	for _, u := range strings.Split(o.URLs, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			o.Endpoints = append(o.Endpoints, u)
+		}
+	}
+	if len(o.Endpoints) == 0 {
+		return nil, nil
+	}
+
+	if o.SecretFile != "" {
+		secret, err := os.ReadFile(o.SecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read notification secret: %w", err)
+		}
+		o.Secret = bytes.TrimSpace(secret)
+	}
+
+	return notify.NewNotifier(o.Options, clock.RealClock{}), nil
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"sigs.k8s.io/yaml"
+
+	"github.com/fabiante/podbouncer/internal/controller"
+)
+
+// Exit codes of the simulate command
+const (
+	exitSimulateSucceeded = 0
+	exitSimulateInvalid   = 2
+)
+
+// simulateTimeLayouts are the accepted layouts of the --now flag.
+var simulateTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+}
+
+// runSimulate implements the "podbouncer simulate" command: It evaluates a proposed
+// configuration against exported pod manifests without cluster access and reports which
+// pods would be deleted, compared to the current configuration.
+func runSimulate(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+
+	var podsFile string
+	var configFile string
+	var currentConfigFile string
+	var nowStr string
+	var guardrails controller.ConfigGuardrails
+	fs.StringVar(&podsFile, "pods", "", "Pod manifests as exported by \"kubectl get pods -A -o yaml\".")
+	fs.StringVar(&configFile, "config", "", "The proposed configuration file.")
+	fs.StringVar(&currentConfigFile, "current-config", "",
+		"The current configuration file to compare against. Defaults to the built-in configuration.")
+	fs.StringVar(&nowStr, "now", "", "The point in time to simulate, e.g. 2026-10-16T12:00Z. Defaults to the current time.")
+	bindGuardrailFlags(fs, &guardrails)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitSimulateSucceeded
+		}
+		return exitSimulateInvalid
+	}
+
+	if err := simulate(out, podsFile, configFile, currentConfigFile, nowStr, guardrails); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitSimulateInvalid
+	}
+
+	return exitSimulateSucceeded
+}
+
+func simulate(out io.Writer, podsFile, configFile, currentConfigFile, nowStr string, guardrails controller.ConfigGuardrails) error {
+	if podsFile == "" || configFile == "" {
+		return errors.New("--pods and --config are required")
+	}
+
+	now := time.Now()
+	if nowStr != "" {
+		var err error
+		if now, err = parseSimulateTime(nowStr); err != nil {
+			return err
+		}
+	}
+
+	pods, err := readPodManifests(podsFile)
+	if err != nil {
+		return err
+	}
+
+	proposed := controller.NewPodReconcilerConfig()
+	if err := (&controller.FileConfigSource{Path: configFile, Config: proposed, Guardrails: guardrails}).Load(); err != nil {
+		return err
+	}
+
+	current := controller.NewPodReconcilerConfig()
+	currentName := "built-in configuration"
+	if currentConfigFile != "" {
+		if err := (&controller.FileConfigSource{Path: currentConfigFile, Config: current, Guardrails: guardrails}).Load(); err != nil {
+			return err
+		}
+		currentName = currentConfigFile
+	}
+
+	proposedDeleted := simulateDeletions(pods, proposed, now)
+	currentDeleted := simulateDeletions(pods, current, now)
+
+	fmt.Fprintf(out, "Simulated at %s against %d pods\n\n", now.Format(time.RFC3339), len(pods))
+	fmt.Fprintf(out, "Pods deleted with %s (%d):\n", configFile, len(proposedDeleted))
+
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "  NAMESPACE\tNAME\tPHASE\tAGE\tRULE")
+	for _, pod := range pods {
+		key := podKey(&pod)
+		if decision, found := proposedDeleted[key]; found {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", pod.Namespace, pod.Name, pod.Status.Phase,
+				duration.HumanDuration(decision.Age), decision.Rule)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "\nDiff versus %s:\n", currentName)
+	changes := 0
+	for _, pod := range pods {
+		key := podKey(&pod)
+		_, deletedNow := currentDeleted[key]
+		_, deletedProposed := proposedDeleted[key]
+
+		switch {
+		case deletedProposed && !deletedNow:
+			fmt.Fprintf(out, "+ %s\n", key)
+			changes++
+		case !deletedProposed && deletedNow:
+			fmt.Fprintf(out, "- %s\n", key)
+			changes++
+		}
+	}
+	if changes == 0 {
+		fmt.Fprintln(out, "  (no changes)")
+	}
+
+	return nil
+}
+
+// simulateDeletions returns the decisions of all pods which would be deleted, keyed by podKey.
+// Expired pods are not deleted while the maintenance windows of config are closed, and no
+// pods are deleted while config is paused.
+func simulateDeletions(pods []v1.Pod, config *controller.PodReconcilerConfig, now time.Time) map[string]controller.PodDecision {
+	deleted := make(map[string]controller.PodDecision)
+	if config.Paused() {
+		return deleted
+	}
+
+	for i := range pods {
+		decision, err := controller.EvaluatePod(&pods[i], config, now)
+		if err == nil && decision.Expired && !controller.DeletionTime(decision, config, now).After(now) {
+			deleted[podKey(&pods[i])] = decision
+		}
+	}
+
+	return deleted
+}
+
+// readPodManifests reads a List of pods or a single pod manifest.
+func readPodManifests(path string) ([]v1.Pod, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pod manifests: %w", err)
+	}
+
+	var list struct {
+		Kind  string   `json:"kind"`
+		Items []v1.Pod `json:"items"`
+	}
+	if err := yaml.Unmarshal(content, &list); err != nil {
+		return nil, fmt.Errorf("invalid pod manifests %s: %w", path, err)
+	}
+
+	switch list.Kind {
+	case "List", "PodList":
+		return list.Items, nil
+	case "Pod":
+		var pod v1.Pod
+		if err := yaml.Unmarshal(content, &pod); err != nil {
+			return nil, fmt.Errorf("invalid pod manifest %s: %w", path, err)
+		}
+		return []v1.Pod{pod}, nil
+	default:
+		return nil, fmt.Errorf("invalid pod manifests %s: unsupported kind %q", path, list.Kind)
+	}
+}
+
+func parseSimulateTime(s string) (time.Time, error) {
+	for _, layout := range simulateTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q, use RFC 3339 format, e.g. 2026-10-16T12:00Z", s)
+}
+
+func podKey(pod *v1.Pod) string {
+	return pod.Namespace + "/" + pod.Name
+}
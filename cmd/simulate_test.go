@@ -0,0 +1,206 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/fabiante/podbouncer/internal/controller"
+)
+
+func Test_ParseSimulateTime(t *testing.T) {
+	noon := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "2026-10-16T12:00:00Z", want: noon},
+		{in: "2026-10-16T12:00:00.5Z", want: noon.Add(500 * time.Millisecond)},
+		{in: "2026-10-16T12:00Z", want: noon},
+		{in: "2026-10-16T14:00+02:00", want: noon},
+		{in: "2026-10-16", want: time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)},
+		{in: "16.10.2026 12:00", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseSimulateTime(tt.in)
+			if tt.wantErr {
+				require.ErrorContains(t, err, "invalid time")
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, tt.want.Equal(got), "got %s", got)
+		})
+	}
+}
+
+const simulatePodsManifest = `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    namespace: default
+    name: old
+    creationTimestamp: "2026-10-16T09:00:00Z"
+  status:
+    phase: Failed
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    namespace: default
+    name: older-than-an-hour
+    creationTimestamp: "2026-10-16T10:30:00Z"
+  status:
+    phase: Succeeded
+- apiVersion: v1
+  kind: Pod
+  metadata:
+    namespace: default
+    name: new
+    creationTimestamp: "2026-10-16T11:40:00Z"
+  status:
+    phase: Failed
+`
+
+func Test_ReadPodManifests(t *testing.T) {
+	pod := "apiVersion: v1\nkind: Pod\nmetadata:\n  namespace: default\n  name: single\n"
+
+	tests := []struct {
+		name     string
+		manifest string
+		want     []string
+		wantErr  string
+	}{
+		{
+			name:     "List",
+			manifest: simulatePodsManifest,
+			want:     []string{"old", "older-than-an-hour", "new"},
+		},
+		{
+			name:     "PodList",
+			manifest: strings.Replace(simulatePodsManifest, "kind: List", "kind: PodList", 1),
+			want:     []string{"old", "older-than-an-hour", "new"},
+		},
+		{
+			name:     "Pod",
+			manifest: pod,
+			want:     []string{"single"},
+		},
+		{
+			name:     "unsupported kind",
+			manifest: strings.Replace(pod, "kind: Pod", "kind: Deployment", 1),
+			wantErr:  `unsupported kind "Deployment"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "pods.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tt.manifest), 0o600))
+
+			pods, err := readPodManifests(path)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var names []string
+			for _, pod := range pods {
+				names = append(names, pod.Name)
+			}
+			require.Equal(t, tt.want, names)
+		})
+	}
+}
+
+func Test_Simulate(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	pods := write("pods.yaml", simulatePodsManifest)
+	current := write("current.yaml", "maxPodAge: 2h\n")
+
+	t.Run("reports the deleted pods and the diff", func(t *testing.T) {
+		proposed := write("proposed.yaml", "maxPodAge: 1h\n")
+
+		var out bytes.Buffer
+		require.NoError(t, simulate(&out, pods, proposed, current, "2026-10-16T12:00Z", controller.ConfigGuardrails{}))
+		require.Equal(t, "Simulated at 2026-10-16T12:00:00Z against 3 pods\n\n"+
+			"Pods deleted with "+proposed+" (2):\n"+
+			"  NAMESPACE   NAME                 PHASE       AGE   RULE\n"+
+			"  default     old                  Failed      3h    phase=Failed\n"+
+			"  default     older-than-an-hour   Succeeded   90m   phase=Succeeded\n"+
+			"\nDiff versus "+current+":\n"+
+			"+ default/older-than-an-hour\n", out.String())
+	})
+
+	tests := []struct {
+		name     string
+		proposed string
+		diff     string
+	}{
+		{
+			name:     "pods no longer deleted",
+			proposed: "maxPodAge: 4h\n",
+			diff:     "- default/old\n",
+		},
+		{
+			name:     "no changes",
+			proposed: "maxPodAge: 2h\n",
+			diff:     "  (no changes)\n",
+		},
+		{
+			name:     "paused",
+			proposed: "maxPodAge: 1h\npaused: \"true\"\n",
+			diff:     "- default/old\n",
+		},
+		{
+			name:     "closed maintenance window",
+			proposed: "maxPodAge: 1h\nmaintenanceWindows: 0 22 * * * 1h\n",
+			diff:     "- default/old\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proposed := write("proposed.yaml", tt.proposed)
+
+			var out bytes.Buffer
+			require.NoError(t, simulate(&out, pods, proposed, current, "2026-10-16T12:00Z", controller.ConfigGuardrails{}))
+
+			_, diff, found := strings.Cut(out.String(), "Diff versus "+current+":\n")
+			require.True(t, found, out.String())
+			require.Equal(t, tt.diff, diff)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+
+	"github.com/fabiante/podbouncer/internal/archive"
+)
+
+// Exit codes of the snapshot command
+const (
+	exitSnapshotSucceeded = 0
+	exitSnapshotNotFound  = 1
+	exitSnapshotInvalid   = 2
+)
+
+// runSnapshot implements the "podbouncer snapshot <uid>" command: It prints the archived
+// snapshot of a deleted pod as YAML.
+func runSnapshot(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: podbouncer snapshot [flags] <pod uid>")
+		fs.PrintDefaults()
+	}
+
+	var o archiveOptions
+	bindArchiveBackendFlags(fs, &o)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitSnapshotSucceeded
+		}
+		return exitSnapshotInvalid
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitSnapshotInvalid
+	}
+
+	backend, err := o.backend()
+	if err == nil && backend == nil {
+		err = errors.New("--log-archive-dir or --log-archive-s3-endpoint is required")
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitSnapshotInvalid
+	}
+
+	snapshot, err := archive.LoadSnapshot(context.Background(), backend, types.UID(fs.Arg(0)))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if errors.Is(err, archive.ErrNotFound) {
+			return exitSnapshotNotFound
+		}
+		return exitSnapshotInvalid
+	}
+
+	data, err := yaml.Marshal(snapshot)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitSnapshotInvalid
+	}
+
+	_, _ = out.Write(data)
+	return exitSnapshotSucceeded
+}
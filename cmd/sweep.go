@@ -0,0 +1,163 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/fabiante/podbouncer/internal/controller"
+)
+
+// Exit codes of the sweep command
+const (
+	exitSweepSucceeded  = 0 // All expired pods have been deleted
+	exitSweepFailed     = 1 // Some expired pods could not be deleted
+	exitSweepInvalid    = 2 // The command or configuration is invalid, nothing has been deleted
+	exitSweepListFailed = 3 // The pods could not be listed, nothing has been deleted
+)
+
+// runSweep implements the "podbouncer sweep --once" command: It deletes all expired pods
+// once and exits. This allows running podbouncer as a CronJob on clusters which forbid
+// long-running operators.
+func runSweep(args []string) int {
+	fs := flag.NewFlagSet("sweep", flag.ContinueOnError)
+
+	var once bool
+	var dryRun bool
+	var deleteFinishedJobs bool
+	var configFile string
+	var concurrency int
+	var timeout time.Duration
+	var guardrails controller.ConfigGuardrails
+	var auditOpts auditOptions
+	var archiveOpts archiveOptions
+	fs.BoolVar(&once, "once", false, "Delete all expired pods once and exit.")
+	fs.BoolVar(&dryRun, "dry-run", false, "Only report expired pods instead of deleting them.")
+	fs.StringVar(&configFile, "config-file", "",
+		"If set, the configuration is loaded from this file instead of from the podbouncer-config ConfigMap.")
+	fs.BoolVar(&deleteFinishedJobs, "delete-finished-jobs", false,
+		"If set, the finished Job controlling an expired pod is deleted instead of the pod, "+
+			"unless the Job sets ttlSecondsAfterFinished.")
+	fs.IntVar(&concurrency, "concurrency", 10, "The maximum number of concurrent deletions.")
+	fs.DurationVar(&timeout, "timeout", 10*time.Minute, "The maximum duration of the sweep.")
+	bindGuardrailFlags(fs, &guardrails)
+	bindAuditFlags(fs, &auditOpts)
+	bindArchiveFlags(fs, &archiveOpts)
+	if f := flag.CommandLine.Lookup("kubeconfig"); f != nil {
+		// Registered by controller-runtime for ctrl.GetConfigOrDie
+		fs.Var(f.Value, f.Name, f.Usage)
+	}
+	opts := zap.Options{}
+	opts.BindFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitSweepSucceeded
+		}
+		return exitSweepInvalid
+	}
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	logger := ctrl.Log.WithName("sweep")
+
+	if !once {
+		fmt.Fprintln(os.Stderr, "sweep requires --once, use --mode=sweep to sweep continuously")
+		return exitSweepInvalid
+	}
+
+	if err := guardrails.Validate(); err != nil {
+		logger.Error(err, "invalid guardrails")
+		return exitSweepInvalid
+	}
+
+	ctx, cancel := context.WithTimeout(ctrl.SetupSignalHandler(), timeout)
+	defer cancel()
+
+	cfg := ctrl.GetConfigOrDie()
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		logger.Error(err, "unable to create client")
+		return exitSweepInvalid
+	}
+
+	config := controller.NewPodReconcilerConfig()
+	if configFile != "" {
+		err = (&controller.FileConfigSource{Path: configFile, Config: config, Guardrails: guardrails}).Load()
+	} else {
+		err = controller.LoadConfigMap(ctx, c, config, guardrails)
+	}
+	if err != nil {
+		logger.Error(err, "unable to load configuration")
+		return exitSweepInvalid
+	}
+
+	observers, auditLog, err := auditOpts.observers(auditActor("sweep-once"))
+	if err != nil {
+		logger.Error(err, "unable to create audit log")
+		return exitSweepInvalid
+	}
+	defer auditLog.Close()
+
+	hooks, err := archiveOpts.hooks(cfg)
+	if err != nil {
+		logger.Error(err, "unable to create log archive")
+		return exitSweepInvalid
+	}
+
+	sweeper := &controller.PodSweeper{
+		Client:             c,
+		Config:             config,
+		Concurrency:        concurrency,
+		DryRun:             dryRun,
+		PreDeleteHooks:     hooks,
+		Observers:          observers,
+		DeleteFinishedJobs: deleteFinishedJobs,
+	}
+
+	return sweepOnce(ctx, sweeper, os.Stdout)
+}
+
+// sweepOnce runs a single sweep, prints its summary to out and returns the exit code.
+func sweepOnce(ctx context.Context, sweeper *controller.PodSweeper, out io.Writer) int {
+	logger := ctrl.Log.WithName("sweep")
+
+	summary, err := sweeper.Sweep(ctx)
+
+	fmt.Fprintf(out, "namespaces=%d pods=%d candidates=%d expired=%d deferred=%d deleted=%d failed=%d dryRun=%t\n",
+		summary.Namespaces, summary.Pods, summary.Candidates, summary.Expired, summary.Deferred, summary.Deleted,
+		summary.Failed, sweeper.DryRun)
+
+	switch {
+	case errors.Is(err, controller.ErrListFailed):
+		logger.Error(err, "sweep failed, no pods have been deleted")
+		return exitSweepListFailed
+	case err != nil:
+		logger.Error(err, "sweep failed")
+		return exitSweepFailed
+	}
+
+	return exitSweepSucceeded
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/fabiante/podbouncer/internal/controller"
+)
+
+func Test_RunSweepInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "help", args: []string{"--help"}, want: exitSweepSucceeded},
+		{name: "unknown flag", args: []string{"--once", "--unknown"}, want: exitSweepInvalid},
+		{name: "without once", args: []string{}, want: exitSweepInvalid},
+		{name: "invalid guardrails", args: []string{"--once", "--max-pod-age-floor=2h", "--max-pod-age-ceiling=1h"}, want: exitSweepInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, runSweep(tt.args))
+		})
+	}
+}
+
+func Test_SweepOnce(t *testing.T) {
+	now := time.Now()
+	namespace := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "expired", CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour))},
+		Status:     v1.PodStatus{Phase: v1.PodFailed},
+	}
+
+	tests := []struct {
+		name        string
+		funcs       interceptor.Funcs
+		dryRun      bool
+		want        int
+		wantSummary string
+	}{
+		{
+			name:        "deleted",
+			want:        exitSweepSucceeded,
+			wantSummary: "namespaces=1 pods=1 candidates=1 expired=1 deferred=0 deleted=1 failed=0 dryRun=false\n",
+		},
+		{
+			name:        "dry run",
+			dryRun:      true,
+			want:        exitSweepSucceeded,
+			wantSummary: "namespaces=1 pods=1 candidates=1 expired=1 deferred=0 deleted=0 failed=0 dryRun=true\n",
+		},
+		{
+			name: "deletion failed",
+			funcs: interceptor.Funcs{
+				Delete: func(context.Context, client.WithWatch, client.Object, ...client.DeleteOption) error {
+					return errors.New("forbidden")
+				},
+			},
+			want:        exitSweepFailed,
+			wantSummary: "namespaces=1 pods=1 candidates=1 expired=1 deferred=0 deleted=0 failed=1 dryRun=false\n",
+		},
+		{
+			name: "list failed",
+			funcs: interceptor.Funcs{
+				List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+					if _, ok := list.(*v1.PodList); ok {
+						return errors.New("timeout")
+					}
+					return c.List(ctx, list, opts...)
+				},
+			},
+			want:        exitSweepListFailed,
+			wantSummary: "namespaces=1 pods=0 candidates=0 expired=0 deferred=0 deleted=0 failed=0 dryRun=false\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(namespace.DeepCopy(), pod.DeepCopy()).
+				WithInterceptorFuncs(tt.funcs).Build()
+			sweeper := &controller.PodSweeper{
+				Client: c,
+				Config: controller.NewPodReconcilerConfig(),
+				DryRun: tt.dryRun,
+			}
+
+			var out bytes.Buffer
+			require.Equal(t, tt.want, sweepOnce(context.Background(), sweeper, &out))
+			require.Equal(t, tt.wantSummary, out.String())
+		})
+	}
+}
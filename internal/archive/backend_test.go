@@ -0,0 +1,57 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_DirBackend(t *testing.T) {
+	root := t.TempDir()
+	b := &DirBackend{Root: root}
+
+	require.NoError(t, b.Put(context.Background(), "default/pod/1234/main.log", strings.NewReader("hello")))
+
+	content, err := os.ReadFile(filepath.Join(root, "default", "pod", "1234", "main.log"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(content))
+
+	entries, err := os.ReadDir(filepath.Join(root, "default", "pod", "1234"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "temporary files should be removed")
+
+	rc, err := b.Get(context.Background(), "default/pod/1234/main.log")
+	require.NoError(t, err)
+	content, err = io.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	require.Equal(t, "hello", string(content))
+
+	_, err = b.Get(context.Background(), "default/pod/5678/main.log")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	for _, key := range []string{"", "..", "../escape.log", "/abs.log"} {
+		require.Error(t, b.Put(context.Background(), key, strings.NewReader("")), "key %q should be rejected", key)
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/fabiante/podbouncer/internal/controller"
+)
+
+// LogArchiver stores the logs of all containers of a pod before it is deleted.
+//
+// The logs of a container are stored at <namespace>/<pod>/<uid>/<container>.log.
+// The logs of the previous instance of restarted containers are stored at
+// <namespace>/<pod>/<uid>/<container>.previous.log.
+type LogArchiver struct {
+	Pods    corev1client.PodsGetter
+	Backend Backend
+
+	// Phases restricts archiving to pods in these phases. Logs of all pods are archived if it is empty.
+	Phases []v1.PodPhase
+
+	// MaxAttempts bounds how often retrieving the logs of a pod is attempted. Once it is
+	// exhausted, the pod is deleted without the logs which could not be retrieved. Retrieval
+	// is retried until it succeeds if it is 0. Failures to store the logs are always retried.
+	MaxAttempts int
+
+	mu       sync.Mutex
+	attempts map[types.UID]int
+}
+
+// errLogsUnavailable is returned by archive if the logs of a container could not be retrieved.
+var errLogsUnavailable = errors.New("logs unavailable")
+
+var _ controller.PreDeleteHook = &LogArchiver{}
+
+// +kubebuilder:rbac:groups=core,resources=pods/log,verbs=get
+
+// BeforeDelete implements controller.PreDeleteHook.
+func (a *LogArchiver) BeforeDelete(ctx context.Context, pod *v1.Pod, _ controller.PodDecision) error {
+	if len(a.Phases) > 0 && !slices.Contains(a.Phases, pod.Status.Phase) {
+		return nil
+	}
+
+	restarts := make(map[string]int32)
+	for _, s := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		restarts[s.Name] = s.RestartCount
+	}
+
+	var unavailable error
+	containers := append(slices.Clone(pod.Spec.InitContainers), pod.Spec.Containers...)
+	for _, c := range containers {
+		err := a.archive(ctx, pod, c.Name, false)
+		if err == nil && restarts[c.Name] > 0 {
+			err = a.archive(ctx, pod, c.Name, true)
+		}
+		if errors.Is(err, errLogsUnavailable) {
+			unavailable = errors.Join(unavailable, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if unavailable != nil {
+		if !a.exhausted(pod.UID) {
+			return unavailable
+		}
+		log.FromContext(ctx).Error(unavailable, "Giving up retrieving pod logs, deleting the pod without them",
+			"attempts", a.MaxAttempts)
+	}
+	a.forget(pod.UID)
+
+	log.FromContext(ctx).Info("Pod logs archived", "containers", len(containers))
+
+	return nil
+}
+
+// archive streams the logs of a single container to the backend. Containers which
+// never started have no logs and are skipped.
+func (a *LogArchiver) archive(ctx context.Context, pod *v1.Pod, container string, previous bool) error {
+	stream, err := a.Pods.Pods(pod.Namespace).GetLogs(pod.Name, &v1.PodLogOptions{
+		Container: container,
+		Previous:  previous,
+	}).Stream(ctx)
+	if apierrors.IsBadRequest(err) || apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: failed to get logs of container %s: %w", errLogsUnavailable, container, err)
+	}
+	defer stream.Close()
+
+	name := container + ".log"
+	if previous {
+		name = container + ".previous.log"
+	}
+
+	key := path.Join(pod.Namespace, pod.Name, string(pod.UID), name)
+	if err := a.Backend.Put(ctx, key, stream); err != nil {
+		return fmt.Errorf("failed to archive logs of container %s: %w", container, err)
+	}
+
+	return nil
+}
+
+// exhausted records a failed attempt to retrieve the logs of the pod and reports whether
+// MaxAttempts is reached.
+func (a *LogArchiver) exhausted(uid types.UID) bool {
+	if a.MaxAttempts <= 0 {
+		return false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.attempts == nil {
+		a.attempts = make(map[types.UID]int)
+	}
+	a.attempts[uid]++
+	return a.attempts[uid] >= a.MaxAttempts
+}
+
+// forget drops the failed attempts of the pod once it is archived.
+func (a *LogArchiver) forget(uid types.UID) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.attempts, uid)
+}
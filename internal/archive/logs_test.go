@@ -0,0 +1,148 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	restclient "k8s.io/client-go/rest"
+	fakerest "k8s.io/client-go/rest/fake"
+
+	"github.com/fabiante/podbouncer/internal/controller"
+)
+
+func Test_LogArchiverBeforeDelete(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "some-pod", UID: "1234"},
+		Spec: v1.PodSpec{
+			InitContainers: []v1.Container{{Name: "init"}},
+			Containers:     []v1.Container{{Name: "main"}, {Name: "sidecar"}},
+		},
+		Status: v1.PodStatus{
+			Phase: v1.PodFailed,
+			ContainerStatuses: []v1.ContainerStatus{
+				{Name: "main", RestartCount: 2},
+				{Name: "sidecar"},
+			},
+		},
+	}
+
+	root := t.TempDir()
+	a := &LogArchiver{
+		Pods:    fake.NewSimpleClientset(pod).CoreV1(),
+		Backend: &DirBackend{Root: root},
+		Phases:  []v1.PodPhase{v1.PodFailed},
+	}
+
+	require.NoError(t, a.BeforeDelete(context.Background(), pod, controller.PodDecision{}))
+
+	dir := filepath.Join(root, "default", "some-pod", "1234")
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	require.ElementsMatch(t, []string{"init.log", "main.log", "main.previous.log", "sidecar.log"}, names)
+
+	content, err := os.ReadFile(filepath.Join(dir, "main.log"))
+	require.NoError(t, err)
+	require.NotEmpty(t, content)
+
+	t.Run("skips other phases", func(t *testing.T) {
+		succeeded := pod.DeepCopy()
+		succeeded.UID = "5678"
+		succeeded.Status.Phase = v1.PodSucceeded
+
+		require.NoError(t, a.BeforeDelete(context.Background(), succeeded, controller.PodDecision{}))
+		require.NoDirExists(t, filepath.Join(root, "default", "some-pod", "5678"))
+	})
+}
+
+// unavailableLogs fails to retrieve the logs of the given container.
+type unavailableLogs struct {
+	corev1client.PodsGetter
+	container string
+}
+
+func (u unavailableLogs) Pods(namespace string) corev1client.PodInterface {
+	return unavailableLogsPods{u.PodsGetter.Pods(namespace), u.container}
+}
+
+type unavailableLogsPods struct {
+	corev1client.PodInterface
+	container string
+}
+
+func (u unavailableLogsPods) GetLogs(name string, opts *v1.PodLogOptions) *restclient.Request {
+	if opts.Container != u.container {
+		return u.PodInterface.GetLogs(name, opts)
+	}
+	client := &fakerest.RESTClient{
+		Client: fakerest.CreateHTTPClient(func(*http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusInternalServerError,
+				Body:       io.NopCloser(strings.NewReader("")),
+			}, nil
+		}),
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+	}
+	return client.Request()
+}
+
+func Test_LogArchiverMaxAttempts(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "some-pod", UID: "1234"},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "main"}, {Name: "sidecar"}}},
+	}
+
+	root := t.TempDir()
+	a := &LogArchiver{
+		Pods:        unavailableLogs{fake.NewSimpleClientset(pod).CoreV1(), "main"},
+		Backend:     &DirBackend{Root: root},
+		MaxAttempts: 3,
+	}
+
+	for range 2 {
+		require.ErrorIs(t, a.BeforeDelete(context.Background(), pod, controller.PodDecision{}), errLogsUnavailable)
+	}
+	require.FileExists(t, filepath.Join(root, "default", "some-pod", "1234", "sidecar.log"))
+
+	require.NoError(t, a.BeforeDelete(context.Background(), pod, controller.PodDecision{}))
+	require.NoFileExists(t, filepath.Join(root, "default", "some-pod", "1234", "main.log"))
+	require.Empty(t, a.attempts)
+
+	t.Run("retries until the logs are retrieved without limit", func(t *testing.T) {
+		a.MaxAttempts = 0
+		for range 5 {
+			require.ErrorIs(t, a.BeforeDelete(context.Background(), pod, controller.PodDecision{}), errLogsUnavailable)
+		}
+	})
+}
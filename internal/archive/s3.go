@@ -0,0 +1,334 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// S3Backend stores objects in a bucket of an S3 compatible object storage.
+//
+// Requests are signed with AWS Signature Version 4 and use path-style URLs,
+// which are supported by AWS as well as by self-hosted implementations like MinIO.
+type S3Backend struct {
+	// Endpoint is the base URL of the object storage, e.g. https://s3.eu-central-1.amazonaws.com.
+	Endpoint string
+	Bucket   string
+	Region   string
+
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// Client is used to send requests. http.DefaultClient is used if it is nil.
+	Client *http.Client
+
+	// Clock is used to sign requests. The real clock is used if it is nil.
+	Clock clock.PassiveClock
+
+	// partSize overrides s3PartSize in tests.
+	partSize int64
+}
+
+// s3PartSize is the size of the parts of multipart uploads. Objects up to this size
+// are uploaded with a single request.
+const s3PartSize = 8 << 20
+
+var _ Backend = &S3Backend{}
+
+// Put implements Backend. Since the signature covers the hash of the payload, the
+// object is read in parts of s3PartSize and only a single part is buffered in memory.
+// Larger objects are uploaded as multipart upload.
+func (b *S3Backend) Put(ctx context.Context, key string, r io.Reader) error {
+	part, err := b.readPart(r)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", key, err)
+	}
+
+	if int64(len(part)) < b.maxPartSize() {
+		resp, err := b.do(ctx, http.MethodPut, key, nil, part)
+		if err != nil {
+			return fmt.Errorf("failed to upload %s: %w", key, err)
+		}
+		resp.Body.Close()
+		return nil
+	}
+
+	if err := b.putMultipart(ctx, key, part, r); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return nil
+}
+
+// putMultipart uploads first and the rest of r as a multipart upload. The upload is
+// aborted if any part fails, so no incomplete parts are left in the bucket.
+func (b *S3Backend) putMultipart(ctx context.Context, key string, first []byte, r io.Reader) error {
+	resp, err := b.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start multipart upload: %w", err)
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&initiated)
+	resp.Body.Close()
+	if err != nil || initiated.UploadID == "" {
+		return fmt.Errorf("invalid response to multipart upload: %w", err)
+	}
+
+	completed, err := b.putParts(ctx, key, initiated.UploadID, first, r)
+	if err == nil {
+		err = b.completeMultipart(ctx, key, initiated.UploadID, completed)
+	}
+	if err != nil {
+		if resp, abortErr := b.do(context.WithoutCancel(ctx), http.MethodDelete, key, url.Values{"uploadId": {initiated.UploadID}}, nil); abortErr == nil {
+			resp.Body.Close()
+		} else {
+			err = errors.Join(err, fmt.Errorf("failed to abort multipart upload: %w", abortErr))
+		}
+		return err
+	}
+
+	return nil
+}
+
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// putParts uploads part and all further parts read from r.
+func (b *S3Backend) putParts(ctx context.Context, key, uploadID string, part []byte, r io.Reader) ([]s3CompletedPart, error) {
+	var completed []s3CompletedPart
+	for number := 1; len(part) > 0; number++ {
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		resp, err := b.do(ctx, http.MethodPut, key, query, part)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload part %d: %w", number, err)
+		}
+		resp.Body.Close()
+		completed = append(completed, s3CompletedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+
+		if part, err = b.readPart(r); err != nil {
+			return nil, err
+		}
+	}
+	return completed, nil
+}
+
+// completeMultipart assembles the uploaded parts. S3 may report errors of this request
+// in the body of a 200 OK response.
+func (b *S3Backend) completeMultipart(ctx context.Context, key, uploadID string, parts []s3CompletedPart) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+		Parts   []s3CompletedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+
+	resp, err := b.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, body)
+	if err != nil {
+		return fmt.Errorf("failed to complete multipart upload: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		XMLName xml.Name
+		Code    string `xml:"Code"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err == nil && result.XMLName.Local == "Error" {
+		return fmt.Errorf("failed to complete multipart upload: %s", result.Code)
+	}
+	return nil
+}
+
+// readPart reads up to maxPartSize bytes from r.
+func (b *S3Backend) readPart(r io.Reader) ([]byte, error) {
+	return io.ReadAll(io.LimitReader(r, b.maxPartSize()))
+}
+
+func (b *S3Backend) maxPartSize() int64 {
+	if b.partSize > 0 {
+		return b.partSize
+	}
+	return s3PartSize
+}
+
+// Get implements Backend.
+func (b *S3Backend) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	resp, err := b.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	return resp.Body, nil
+}
+
+// do sends a signed request for the object with the given key. Responses with
+// a status other than 2xx are returned as error.
+func (b *S3Backend) do(ctx context.Context, method, key string, query url.Values, payload []byte) (*http.Response, error) {
+	endpoint, err := url.Parse(b.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint: %w", err)
+	}
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + "/" + b.Bucket + "/" + strings.TrimPrefix(key, "/")
+	endpoint.RawQuery = query.Encode()
+
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(payload)
+	signV4(req, hex.EncodeToString(sum[:]), b.AccessKeyID, b.SecretAccessKey, b.Region, b.now())
+
+	c := b.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	return resp, nil
+}
+
+func (b *S3Backend) now() time.Time {
+	if b.Clock == nil {
+		return time.Now()
+	}
+	return b.Clock.Now()
+}
+
+// signV4 adds the x-amz-date, x-amz-content-sha256 and Authorization headers to req.
+// The host and all headers already present on req are signed.
+func signV4(req *http.Request, payloadHash, accessKeyID, secretAccessKey, region string, now time.Time) {
+	const service = "s3"
+
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery returns the query parameters sorted by name and URI encoded.
+func canonicalQuery(query url.Values) string {
+	params := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// uriEncode encodes s as specified for Signature Version 4: All bytes except the
+// unreserved characters are percent encoded. Slashes are kept unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/fabiante/podbouncer/internal/controller"
+)
+
+// maxSnapshotEvents is the number of most recent events stored with a snapshot.
+const maxSnapshotEvents = 50
+
+// Snapshot is the final state of a pod before it was deleted.
+type Snapshot struct {
+	Time   time.Time  `json:"time"`
+	Rule   string     `json:"rule"`
+	Pod    *v1.Pod    `json:"pod"`
+	Events []v1.Event `json:"events"`
+}
+
+// SnapshotKey returns the key of the snapshot of the pod with the given UID.
+func SnapshotKey(uid types.UID) string {
+	return "snapshots/" + string(uid) + ".json.gz"
+}
+
+// Snapshotter stores the complete pod object, including spec and status, and its
+// most recent events before it is deleted. Snapshots are stored as gzip compressed
+// JSON at the key returned by SnapshotKey.
+type Snapshotter struct {
+	// Client is used to read the pod and its events. The pod is read from the API server
+	// since the cached pods lack most of the spec.
+	Client  corev1client.CoreV1Interface
+	Backend Backend
+
+	// Clock is used to timestamp snapshots. The real clock is used if it is nil.
+	Clock clock.PassiveClock
+}
+
+var _ controller.PreDeleteHook = &Snapshotter{}
+
+// +kubebuilder:rbac:groups=core,resources=events,verbs=list
+
+// BeforeDelete implements controller.PreDeleteHook.
+func (s *Snapshotter) BeforeDelete(ctx context.Context, pod *v1.Pod, decision controller.PodDecision) error {
+	current, err := s.Client.Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get pod: %w", err)
+	}
+	if current.UID != pod.UID {
+		// The pod has been replaced by another pod with the same name
+		return nil
+	}
+	current.ManagedFields = nil
+
+	events, err := s.events(ctx, current)
+	if err != nil {
+		return err
+	}
+
+	snapshot := Snapshot{
+		Time:   s.now().UTC(),
+		Rule:   decision.Rule,
+		Pod:    current,
+		Events: events,
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(writeSnapshot(w, &snapshot))
+	}()
+	defer r.Close()
+
+	if err := s.Backend.Put(ctx, SnapshotKey(pod.UID), r); err != nil {
+		return fmt.Errorf("failed to store snapshot: %w", err)
+	}
+
+	log.FromContext(ctx).Info("Pod snapshot stored", "uid", pod.UID, "events", len(events))
+
+	return nil
+}
+
+// events returns the most recent events of pod, oldest first.
+func (s *Snapshotter) events(ctx context.Context, pod *v1.Pod) ([]v1.Event, error) {
+	list, err := s.Client.Events(pod.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("involvedObject.uid", string(pod.UID)).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	var events []v1.Event
+	for _, e := range list.Items {
+		if e.InvolvedObject.UID == pod.UID {
+			e.ManagedFields = nil
+			events = append(events, e)
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(&events[i]).Before(eventTime(&events[j]))
+	})
+	if len(events) > maxSnapshotEvents {
+		events = events[len(events)-maxSnapshotEvents:]
+	}
+
+	return events, nil
+}
+
+func (s *Snapshotter) now() time.Time {
+	if s.Clock == nil {
+		return time.Now()
+	}
+	return s.Clock.Now()
+}
+
+// eventTime returns the time an event was last observed.
+func eventTime(e *v1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	default:
+		return e.CreationTimestamp.Time
+	}
+}
+
+func writeSnapshot(w io.Writer, snapshot *Snapshot) error {
+	gz := gzip.NewWriter(w)
+	if err := json.NewEncoder(gz).Encode(snapshot); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// LoadSnapshot reads the snapshot of the pod with the given UID from backend.
+func LoadSnapshot(ctx context.Context, backend Backend, uid types.UID) (*Snapshot, error) {
+	rc, err := backend.Get(ctx, SnapshotKey(uid))
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	gz, err := gzip.NewReader(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress snapshot: %w", err)
+	}
+	defer gz.Close()
+
+	var snapshot Snapshot
+	if err := json.NewDecoder(gz).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	return &snapshot, nil
+}
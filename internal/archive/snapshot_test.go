@@ -0,0 +1,95 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package archive
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	clocktesting "k8s.io/utils/clock/testing"
+
+	"github.com/fabiante/podbouncer/internal/controller"
+)
+
+func Test_SnapshotterBeforeDelete(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "some-pod", UID: "1234"},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: "main", Image: "busybox", Command: []string{"false"}}},
+		},
+		Status: v1.PodStatus{
+			Phase: v1.PodFailed,
+			ContainerStatuses: []v1.ContainerStatus{{
+				Name: "main",
+				State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+					ExitCode: 1,
+					Message:  "boom",
+				}},
+			}},
+		},
+	}
+	event := func(name, uid string, at time.Time) *v1.Event {
+		return &v1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Namespace: "default", Name: name},
+			InvolvedObject: v1.ObjectReference{Kind: "Pod", Name: "some-pod", UID: types.UID(uid)},
+			LastTimestamp:  metav1.NewTime(at),
+		}
+	}
+
+	clientset := fake.NewSimpleClientset(
+		pod,
+		event("started", "1234", now.Add(-2*time.Minute)),
+		event("scheduled", "1234", now.Add(-3*time.Minute)),
+		event("other", "5678", now.Add(-time.Minute)),
+	)
+
+	backend := &DirBackend{Root: t.TempDir()}
+	s := &Snapshotter{
+		Client:  clientset.CoreV1(),
+		Backend: backend,
+		Clock:   clocktesting.NewFakePassiveClock(now),
+	}
+
+	// The cached pod lacks the spec, the snapshot contains the pod read from the API server
+	cached := pod.DeepCopy()
+	cached.Spec = v1.PodSpec{}
+	require.NoError(t, s.BeforeDelete(context.Background(), cached, controller.PodDecision{Rule: "phase=Failed"}))
+
+	snapshot, err := LoadSnapshot(context.Background(), backend, "1234")
+	require.NoError(t, err)
+	require.Equal(t, now, snapshot.Time)
+	require.Equal(t, "phase=Failed", snapshot.Rule)
+	require.Equal(t, pod.Spec, snapshot.Pod.Spec)
+	require.Equal(t, pod.Status, snapshot.Pod.Status)
+
+	var names []string
+	for _, e := range snapshot.Events {
+		names = append(names, e.Name)
+	}
+	require.Equal(t, []string{"scheduled", "started"}, names, "events of the pod should be ordered oldest first")
+
+	_, err = LoadSnapshot(context.Background(), backend, "5678")
+	require.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package audit writes one structured record per pod deletion decision to a dedicated
+// sink, separate from the controller log.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/fabiante/podbouncer/internal/controller"
+)
+
+// Record describes a single pod deletion decision.
+type Record struct {
+	Time time.Time `json:"time"`
+	controller.PodRecord
+	DryRun bool   `json:"dryRun"`
+	Actor  string `json:"actor"`
+}
+
+// Logger writes a JSON record per line for every observed deletion.
+//
+// It implements controller.DeletionObserver. Use NewLogger to create instances.
+type Logger struct {
+	actor string
+	clock clock.PassiveClock
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+var _ controller.DeletionObserver = &Logger{}
+
+// NewLogger creates a Logger writing to w. The actor identifies this podbouncer
+// instance in every record.
+func NewLogger(w io.Writer, actor string, clk clock.PassiveClock) *Logger {
+	return &Logger{actor: actor, clock: clk, w: w}
+}
+
+// ObserveDeletion implements controller.DeletionObserver.
+func (l *Logger) ObserveDeletion(ctx context.Context, pod *v1.Pod, decision controller.PodDecision, dryRun bool) {
+	record := Record{
+		Time:      l.clock.Now().UTC(),
+		PodRecord: controller.NewPodRecord(pod, decision),
+		DryRun:    dryRun,
+		Actor:     l.actor,
+	}
+
+	if err := l.Write(record); err != nil {
+		// Never fail a deletion because of the audit log, but make the gap visible
+		log.FromContext(ctx).Error(err, "Failed to write audit record", "uid", pod.UID)
+	}
+}
+
+// Write writes a single record as a line of JSON.
+func (l *Logger) Write(record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.w.Write(data); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+
+	"github.com/fabiante/podbouncer/internal/controller"
+)
+
+func Test_LoggerObserveDeletion(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	l := NewLogger(&buf, "podbouncer-event@replica-0", clocktesting.NewFakePassiveClock(now))
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "some-job-abcde",
+			UID:       "1234",
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "batch/v1", Kind: "Job", Name: "some-job", Controller: ptr.To(true)},
+			},
+		},
+		Status: v1.PodStatus{Phase: v1.PodFailed, Reason: "DeadlineExceeded"},
+	}
+	decision := controller.PodDecision{Candidate: true, Expired: true, Rule: "phase=Failed", Age: 90*time.Minute + 300*time.Millisecond, MaxPodAge: time.Hour}
+
+	l.ObserveDeletion(context.Background(), pod, decision, false)
+	l.ObserveDeletion(context.Background(), pod, decision, true)
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2, "expected one line per record")
+
+	var record Record
+	require.NoError(t, json.Unmarshal(lines[0], &record))
+	require.Equal(t, Record{
+		Time: now,
+		PodRecord: controller.PodRecord{
+			UID:       "1234",
+			Namespace: "default",
+			Name:      "some-job-abcde",
+			Owner:     "Job/some-job",
+			Phase:     v1.PodFailed,
+			Reason:    "DeadlineExceeded",
+			Age:       "1h30m0s",
+			MaxPodAge: "1h0m0s",
+			Rule:      "phase=Failed",
+		},
+		DryRun: false,
+		Actor:  "podbouncer-event@replica-0",
+	}, record)
+
+	require.NoError(t, json.Unmarshal(lines[1], &record))
+	require.True(t, record.DryRun)
+}
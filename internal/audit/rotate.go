@@ -0,0 +1,112 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an io.WriteCloser appending to a file which is rotated once it
+// exceeds a maximum size. Rotated files are renamed to <path>.1, <path>.2, ... with
+// <path>.1 being the most recent one. Use OpenRotatingFile to create instances.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// OpenRotatingFile opens path for appending. The file is rotated before a write
+// would exceed maxSize bytes and at most maxBackups rotated files are kept.
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write implements io.Writer. A single write is never split across files.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close implements io.Closer.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Close()
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// rotate shifts all backups by one, dropping the oldest, and starts a new file.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+
+	if f.maxBackups > 0 {
+		_ = os.Remove(f.backupPath(f.maxBackups))
+		for i := f.maxBackups - 1; i >= 1; i-- {
+			_ = os.Rename(f.backupPath(i), f.backupPath(i+1))
+		}
+		if err := os.Rename(f.path, f.backupPath(1)); err != nil {
+			return fmt.Errorf("failed to rotate audit log: %w", err)
+		}
+	} else if err := os.Remove(f.path); err != nil {
+		return fmt.Errorf("failed to rotate audit log: %w", err)
+	}
+
+	return f.open()
+}
+
+func (f *RotatingFile) backupPath(i int) string {
+	return fmt.Sprintf("%s.%d", f.path, i)
+}
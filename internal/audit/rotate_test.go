@@ -0,0 +1,59 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	f, err := OpenRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	read := func(path string) string {
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return string(content)
+	}
+
+	require.Equal(t, "fourth\n", read(path))
+	require.Equal(t, "third\n", read(path+".1"))
+	require.Equal(t, "second\n", read(path+".2"))
+	require.NoFileExists(t, path+".3", "only maxBackups files should be kept")
+
+	t.Run("appends to existing file", func(t *testing.T) {
+		f, err := OpenRotatingFile(path, 100, 2)
+		require.NoError(t, err)
+		_, err = f.Write([]byte("fifth\n"))
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		require.Equal(t, "fourth\nfifth\n", read(path))
+	})
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cloudevents emits podbouncer decisions as CloudEvents 1.0 in structured
+// JSON mode over HTTP.
+package cloudevents
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/fabiante/podbouncer/internal/controller"
+	"github.com/fabiante/podbouncer/internal/webhook"
+)
+
+// Types of the emitted events.
+const (
+	TypePodDeleted              = "io.podbouncer.pod.deleted"
+	TypePodScheduledForDeletion = "io.podbouncer.pod.scheduled-for-deletion"
+	TypeConfigUpdated           = "io.podbouncer.config.updated"
+)
+
+const (
+	specVersion           = "1.0"
+	contentTypeStructured = "application/cloudevents+json"
+	dataContentTypeJSON   = "application/json"
+)
+
+// Event is a CloudEvent in structured JSON mode.
+type Event struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// PodData is the data of pod events.
+type PodData struct {
+	controller.PodRecord
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// ConfigData is the data of config events.
+type ConfigData struct {
+	Source            string `json:"source"`
+	MaxPodAge         string `json:"maxPodAge"`
+	PreviousMaxPodAge string `json:"previousMaxPodAge"`
+}
+
+// Options configure an Emitter.
+type Options struct {
+	// Sink is the URL events are posted to.
+	Sink string
+
+	// Source is the source attribute of all events, e.g. a URI reference identifying
+	// this podbouncer installation.
+	Source string
+
+	// QueueSize is the maximum number of events waiting to be sent. Events are
+	// dropped while the queue is full.
+	QueueSize int
+
+	// MaxAttempts is the number of attempts to deliver an event.
+	MaxAttempts int
+
+	// RetryDelay is the delay before the second attempt. It doubles with every attempt.
+	RetryDelay time.Duration
+
+	// Client is used to send requests. http.DefaultClient is used if it is nil.
+	Client *http.Client
+}
+
+// DefaultOptions returns the default options without a sink.
+func DefaultOptions() Options {
+	return Options{
+		Source:      "podbouncer",
+		QueueSize:   1000,
+		MaxAttempts: 5,
+		RetryDelay:  time.Second,
+	}
+}
+
+// Emitter sends an event for every deleted pod, pod scheduled for deletion and
+// configuration update to a sink.
+//
+// It implements the observer interfaces of the controller package and queues events
+// without blocking. Deletions in dry run mode are ignored. Use NewEmitter to create instances.
+type Emitter struct {
+	options Options
+	clock   clock.Clock
+	poster  *webhook.Poster
+	queue   chan Event
+}
+
+var (
+	_ controller.DeletionObserver = &Emitter{}
+	_ controller.ScheduleObserver = &Emitter{}
+	_ controller.ConfigObserver   = &Emitter{}
+)
+
+func NewEmitter(o Options, clk clock.Clock) *Emitter {
+	return &Emitter{
+		options: o,
+		clock:   clk,
+		poster:  &webhook.Poster{Client: o.Client, Clock: clk, MaxAttempts: o.MaxAttempts, RetryDelay: o.RetryDelay},
+		queue:   make(chan Event, o.QueueSize),
+	}
+}
+
+// ObserveDeletion implements controller.DeletionObserver.
+func (e *Emitter) ObserveDeletion(ctx context.Context, pod *v1.Pod, decision controller.PodDecision, dryRun bool) {
+	if dryRun {
+		return
+	}
+	e.emit(ctx, TypePodDeleted, pod.Namespace+"/"+pod.Name, PodData{PodRecord: controller.NewPodRecord(pod, decision)})
+}
+
+// ObserveSchedule implements controller.ScheduleObserver.
+func (e *Emitter) ObserveSchedule(ctx context.Context, pod *v1.Pod, decision controller.PodDecision) {
+	expiresAt := decision.ExpiresAt.UTC()
+	data := PodData{PodRecord: controller.NewPodRecord(pod, decision), ExpiresAt: &expiresAt}
+
+	e.emit(ctx, TypePodScheduledForDeletion, pod.Namespace+"/"+pod.Name, data)
+}
+
+// ObserveConfigUpdate implements controller.ConfigObserver.
+func (e *Emitter) ObserveConfigUpdate(ctx context.Context, update controller.ConfigUpdate) {
+	e.emit(ctx, TypeConfigUpdated, update.Source, ConfigData{
+		Source:            update.Source,
+		MaxPodAge:         update.MaxPodAge.String(),
+		PreviousMaxPodAge: update.PreviousMaxPodAge.String(),
+	})
+}
+
+// emit queues an event of the given type.
+func (e *Emitter) emit(ctx context.Context, eventType, subject string, data any) {
+	logger := log.FromContext(ctx)
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		logger.Error(err, "Failed to encode CloudEvent", "type", eventType)
+		return
+	}
+
+	event := Event{
+		SpecVersion:     specVersion,
+		ID:              string(uuid.NewUUID()),
+		Source:          e.options.Source,
+		Type:            eventType,
+		Subject:         subject,
+		Time:            e.clock.Now().UTC(),
+		DataContentType: dataContentTypeJSON,
+		Data:            raw,
+	}
+
+	select {
+	case e.queue <- event:
+	default:
+		droppedEventsTotal.WithLabelValues(eventType).Inc()
+		logger.Info("CloudEvents queue is full, dropping event", "type", eventType, "subject", subject)
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Events are sent by
+// every replica which observes decisions.
+func (e *Emitter) NeedLeaderElection() bool {
+	return false
+}
+
+// Start sends queued events until ctx is done.
+func (e *Emitter) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("cloudevents")
+
+	for {
+		select {
+		case event := <-e.queue:
+			if err := e.deliver(ctx, event); err != nil {
+				failedEventsTotal.WithLabelValues(event.Type).Inc()
+				logger.Error(err, "Failed to send CloudEvent", "type", event.Type, "subject", event.Subject)
+				continue
+			}
+			sentEventsTotal.WithLabelValues(event.Type).Inc()
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// deliver posts event to the sink.
+func (e *Emitter) deliver(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return e.poster.Post(ctx, e.options.Sink, http.Header{"Content-Type": {contentTypeStructured}}, body)
+}
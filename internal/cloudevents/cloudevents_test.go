@@ -0,0 +1,122 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudevents
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/clock"
+
+	"github.com/fabiante/podbouncer/internal/controller"
+)
+
+func Test_Emitter(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests int
+		events   []Event
+	)
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// The first request fails and is retried
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		if r.Header.Get("Content-Type") != "application/cloudevents+json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		var e Event
+		if err := json.Unmarshal(body, &e); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		events = append(events, e)
+	}))
+	defer sink.Close()
+
+	o := DefaultOptions()
+	o.Sink = sink.URL
+	o.Source = "//podbouncer/test"
+	o.RetryDelay = time.Millisecond
+	emitter := NewEmitter(o, clock.RealClock{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = emitter.Start(ctx) }()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "some-pod", UID: "1234"},
+		Status:     v1.PodStatus{Phase: v1.PodSucceeded},
+	}
+	decision := controller.PodDecision{Rule: "phase=Succeeded", Age: 10 * time.Minute, MaxPodAge: time.Hour, ExpiresAt: now.Add(50 * time.Minute)}
+
+	emitter.ObserveSchedule(ctx, pod, decision)
+	emitter.ObserveDeletion(ctx, pod, decision, true)
+	emitter.ObserveDeletion(ctx, pod, decision, false)
+	emitter.ObserveConfigUpdate(ctx, controller.ConfigUpdate{Source: "file:/etc/podbouncer/config.yaml", MaxPodAge: 2 * time.Hour, PreviousMaxPodAge: time.Hour})
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(events) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	var types []string
+	for _, e := range events {
+		require.Equal(t, "1.0", e.SpecVersion)
+		require.Equal(t, "//podbouncer/test", e.Source)
+		require.Equal(t, "application/json", e.DataContentType)
+		require.NotEmpty(t, e.ID)
+		types = append(types, e.Type)
+	}
+	require.Equal(t, []string{TypePodScheduledForDeletion, TypePodDeleted, TypeConfigUpdated}, types, "dry run deletions should be ignored")
+	require.NotEqual(t, events[0].ID, events[1].ID)
+	require.Equal(t, "default/some-pod", events[0].Subject)
+
+	var scheduled PodData
+	require.NoError(t, json.Unmarshal(events[0].Data, &scheduled))
+	require.Equal(t, "1234", string(scheduled.UID))
+	require.Equal(t, "10m0s", scheduled.Age)
+	require.Equal(t, now.Add(50*time.Minute), *scheduled.ExpiresAt)
+
+	var deleted PodData
+	require.NoError(t, json.Unmarshal(events[1].Data, &deleted))
+	require.Nil(t, deleted.ExpiresAt)
+
+	var config ConfigData
+	require.NoError(t, json.Unmarshal(events[2].Data, &config))
+	require.Equal(t, ConfigData{Source: "file:/etc/podbouncer/config.yaml", MaxPodAge: "2h0m0s", PreviousMaxPodAge: "1h0m0s"}, config)
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudevents
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	sentEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "podbouncer_cloudevents_sent_total",
+		Help: "Number of CloudEvents delivered to the sink by their type.",
+	}, []string{"type"})
+
+	failedEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "podbouncer_cloudevents_failed_total",
+		Help: "Number of CloudEvents which could not be delivered after all attempts by their type.",
+	}, []string{"type"})
+
+	droppedEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "podbouncer_cloudevents_dropped_total",
+		Help: "Number of CloudEvents dropped because the queue was full by their type.",
+	}, []string{"type"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		sentEventsTotal,
+		failedEventsTotal,
+		droppedEventsTotal,
+	)
+}
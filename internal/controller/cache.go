@@ -0,0 +1,115 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// PodCacheOptions returns the cache options for pods.
+//
+// PodReconciler never acts on running pods or pods of the excluded namespace,
+// so these are not cached at all. The remaining pods are passed through TransformPod
+// before they are stored.
+func PodCacheOptions() cache.ByObject {
+	return cache.ByObject{
+		Field: fields.AndSelectors(
+			fields.OneTermNotEqualSelector("status.phase", string(v1.PodRunning)),
+			fields.OneTermNotEqualSelector("metadata.namespace", excludedNamespace),
+		),
+		Transform: TransformPod,
+	}
+}
+
+// TransformPod reduces pods to the slim representation podbouncer works with, reducing the
+// memory used by the cache. Objects other than pods are returned unchanged.
+//
+// PodReconciler only reads metadata, phase, reason, conditions and container states.
+// Managed fields, the last applied configuration, volumes and most of the spec are dropped.
+// Containers are kept with their name and image only.
+func TransformPod(obj interface{}) (interface{}, error) {
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		return obj, nil
+	}
+
+	pod.ManagedFields = nil
+	delete(pod.Annotations, v1.LastAppliedConfigAnnotation)
+
+	spec := &pod.Spec
+	spec.Volumes = nil
+	spec.Affinity = nil
+	spec.Tolerations = nil
+	spec.NodeSelector = nil
+	spec.TopologySpreadConstraints = nil
+	spec.SecurityContext = nil
+	spec.HostAliases = nil
+	spec.DNSConfig = nil
+	spec.ReadinessGates = nil
+	spec.Overhead = nil
+	spec.SchedulingGates = nil
+	spec.ResourceClaims = nil
+	spec.EphemeralContainers = nil
+	spec.ImagePullSecrets = nil
+
+	spec.InitContainers = slimContainers(spec.InitContainers)
+	spec.Containers = slimContainers(spec.Containers)
+
+	return pod, nil
+}
+
+// slimContainers returns containers reduced to their name and image.
+func slimContainers(containers []v1.Container) []v1.Container {
+	if containers == nil {
+		return nil
+	}
+
+	slim := make([]v1.Container, len(containers))
+	for i, c := range containers {
+		slim[i] = v1.Container{Name: c.Name, Image: c.Image}
+	}
+	return slim
+}
+
+// JobCacheOptions returns the cache options for Jobs, which are read to delete finished
+// Jobs instead of their pods. Jobs are passed through TransformJob before they are stored.
+func JobCacheOptions() cache.ByObject {
+	return cache.ByObject{
+		Transform: TransformJob,
+	}
+}
+
+// TransformJob reduces Jobs to their metadata, ttlSecondsAfterFinished, number of active
+// pods and conditions, which is all podbouncer reads of them. The pod template, which makes up most of a Job,
+// is dropped. Objects other than Jobs are returned unchanged.
+func TransformJob(obj interface{}) (interface{}, error) {
+	job, ok := obj.(*batchv1.Job)
+	if !ok {
+		return obj, nil
+	}
+
+	job.ManagedFields = nil
+	delete(job.Annotations, v1.LastAppliedConfigAnnotation)
+
+	job.Spec = batchv1.JobSpec{TTLSecondsAfterFinished: job.Spec.TTLSecondsAfterFinished}
+	job.Status = batchv1.JobStatus{Active: job.Status.Active, Conditions: job.Status.Conditions}
+
+	return job, nil
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newCachedPod returns a pod resembling a typical workload pod as stored in the cache.
+func newCachedPod(i int) *v1.Pod {
+	env := make([]v1.EnvVar, 0, 20)
+	for j := 0; j < 20; j++ {
+		env = append(env, v1.EnvVar{Name: fmt.Sprintf("SOME_SETTING_%d", j), Value: fmt.Sprintf("some-fairly-long-value-%d", j)})
+	}
+
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      fmt.Sprintf("some-job-%d", i),
+			Labels:    map[string]string{"app.kubernetes.io/name": "some-job"},
+			Annotations: map[string]string{
+				v1.LastAppliedConfigAnnotation: string(make([]byte, 1024)),
+				"example.com/owner":            "some-team",
+			},
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "kube-controller-manager", Operation: metav1.ManagedFieldsOperationUpdate, FieldsV1: &metav1.FieldsV1{Raw: make([]byte, 2048)}},
+				{Manager: "kubelet", Operation: metav1.ManagedFieldsOperationUpdate, FieldsV1: &metav1.FieldsV1{Raw: make([]byte, 1024)}},
+			},
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{
+				Name:         "main",
+				Image:        "registry.example.com/some-job:1.0.0",
+				Env:          env,
+				VolumeMounts: []v1.VolumeMount{{Name: "data", MountPath: "/data"}},
+			}},
+			Volumes: []v1.Volume{{Name: "data", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}}},
+			Affinity: &v1.Affinity{NodeAffinity: &v1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{NodeSelectorTerms: []v1.NodeSelectorTerm{{
+					MatchExpressions: []v1.NodeSelectorRequirement{{Key: "kubernetes.io/arch", Operator: v1.NodeSelectorOpIn, Values: []string{"amd64", "arm64"}}},
+				}}},
+			}},
+			Tolerations: []v1.Toleration{
+				{Key: "node.kubernetes.io/not-ready", Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoExecute},
+				{Key: "node.kubernetes.io/unreachable", Operator: v1.TolerationOpExists, Effect: v1.TaintEffectNoExecute},
+			},
+			NodeSelector: map[string]string{"kubernetes.io/os": "linux"},
+		},
+		Status: v1.PodStatus{
+			Phase:  v1.PodFailed,
+			Reason: "Error",
+			Conditions: []v1.PodCondition{
+				{Type: v1.PodReady, Status: v1.ConditionFalse},
+			},
+			ContainerStatuses: []v1.ContainerStatus{{
+				Name:  "main",
+				State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 1, Reason: "Error"}},
+			}},
+		},
+	}
+}
+
+func Test_TransformPod(t *testing.T) {
+	pod := newCachedPod(0)
+	expectedMeta := *pod.ObjectMeta.DeepCopy()
+	expectedStatus := *pod.Status.DeepCopy()
+
+	transformed, err := TransformPod(pod)
+	require.NoError(t, err)
+
+	result := transformed.(*v1.Pod)
+	require.Equal(t, expectedStatus, result.Status, "status must not be changed")
+	require.Equal(t, expectedMeta.Name, result.Name)
+	require.Equal(t, expectedMeta.Labels, result.Labels)
+	require.Equal(t, map[string]string{"example.com/owner": "some-team"}, result.Annotations)
+	require.Nil(t, result.Spec.Affinity)
+	require.Nil(t, result.Spec.Tolerations)
+	require.Nil(t, result.Spec.NodeSelector)
+	require.Nil(t, result.ManagedFields)
+	require.Nil(t, result.Spec.Volumes)
+	require.Equal(t, []v1.Container{{Name: "main", Image: "registry.example.com/some-job:1.0.0"}}, result.Spec.Containers)
+
+	t.Run("ignores other objects", func(t *testing.T) {
+		configMap := &v1.ConfigMap{Data: map[string]string{"maxPodAge": "1h"}}
+		transformed, err := TransformPod(configMap)
+		require.NoError(t, err)
+		require.Same(t, configMap, transformed)
+	})
+}
+
+func Test_TransformJob(t *testing.T) {
+	ttl := int32(60)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "some-job",
+			UID:         "1234",
+			Annotations: map[string]string{v1.LastAppliedConfigAnnotation: "{}", "example.com/owner": "some-team"},
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "kube-controller-manager", Operation: metav1.ManagedFieldsOperationUpdate},
+			},
+		},
+		Spec: batchv1.JobSpec{
+			TTLSecondsAfterFinished: &ttl,
+			Template:                v1.PodTemplateSpec{Spec: newCachedPod(0).Spec},
+		},
+		Status: batchv1.JobStatus{
+			Active:     1,
+			Succeeded:  1,
+			Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: v1.ConditionTrue}},
+		},
+	}
+
+	transformed, err := TransformJob(job)
+	require.NoError(t, err)
+
+	result := transformed.(*batchv1.Job)
+	require.Equal(t, "some-job", result.Name)
+	require.Equal(t, map[string]string{"example.com/owner": "some-team"}, result.Annotations)
+	require.Nil(t, result.ManagedFields)
+	require.Equal(t, batchv1.JobSpec{TTLSecondsAfterFinished: &ttl}, result.Spec)
+	require.Equal(t, batchv1.JobStatus{Active: 1, Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: v1.ConditionTrue}}}, result.Status)
+	require.Equal(t, batchv1.JobComplete, jobCondition(result))
+
+	t.Run("ignores other objects", func(t *testing.T) {
+		pod := newCachedPod(0)
+		transformed, err := TransformJob(pod)
+		require.NoError(t, err)
+		require.Same(t, pod, transformed)
+	})
+}
+
+// Benchmark_TransformPod reports the serialized size of a pod before and after TransformPod.
+func Benchmark_TransformPod(b *testing.B) {
+	b.ReportMetric(float64(newCachedPod(0).Size()), "full-bytes/pod")
+
+	var size int
+	for i := 0; i < b.N; i++ {
+		transformed, _ := TransformPod(newCachedPod(i))
+		size = transformed.(*v1.Pod).Size()
+	}
+
+	b.ReportMetric(float64(size), "transformed-bytes/pod")
+}
+
+// Benchmark_PodCacheHeap compares the heap used by a cache of full pod objects
+// against a cache of pods passed through TransformPod.
+func Benchmark_PodCacheHeap(b *testing.B) {
+	const pods = 1000
+
+	measure := func(b *testing.B, transform bool) {
+		var heapPerPod float64
+		for i := 0; i < b.N; i++ {
+			before := heapAlloc()
+
+			store := make(map[string]*v1.Pod, pods)
+			for j := 0; j < pods; j++ {
+				pod := newCachedPod(j)
+				if transform {
+					transformed, _ := TransformPod(pod)
+					pod = transformed.(*v1.Pod)
+				}
+				store[pod.Name] = pod
+			}
+
+			heapPerPod = float64(heapAlloc()-before) / pods
+			runtime.KeepAlive(store)
+		}
+		b.ReportMetric(heapPerPod, "heap-bytes/pod")
+	}
+
+	b.Run("full", func(b *testing.B) { measure(b, false) })
+	b.Run("transformed", func(b *testing.B) { measure(b, true) })
+}
+
+// heapAlloc returns the bytes of allocated heap objects after a garbage collection.
+func heapAlloc() uint64 {
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Reason of the warning event emitted on owners whose pods are deleted repeatedly.
+const reasonPodChurn = "PodChurn"
+
+// churnPruneInterval is the time between two checks for owners whose deletions have left the
+// churn window. Otherwise owners whose pods are no longer deleted would keep counting as churning.
+const churnPruneInterval = time.Minute
+
+// churnEnabled reports whether deletions are tracked per owner.
+func (r *PodReconciler) churnEnabled() bool {
+	return r.ChurnWindow > 0 && r.ChurnThreshold > 0
+}
+
+// churningUntil reports whether pods of the controller of pod are currently deleted
+// at least ChurnThreshold times within ChurnWindow, and until when.
+func (r *PodReconciler) churningUntil(pod *v1.Pod) (time.Time, bool) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil || !r.churnEnabled() {
+		return time.Time{}, false
+	}
+	return r.churn.churningUntil(owner.UID, r.Clock.Now(), r.ChurnWindow, r.ChurnThreshold)
+}
+
+// recordChurn tracks the deletion of pod for its controller and emits a warning event
+// on the controller once it crosses ChurnThreshold.
+func (r *PodReconciler) recordChurn(ctx context.Context, pod *v1.Pod) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil || !r.churnEnabled() {
+		return
+	}
+
+	count, crossed := r.churn.record(owner.UID, r.Clock.Now(), r.ChurnWindow, r.ChurnThreshold)
+	if !crossed {
+		return
+	}
+
+	ownerChurnDetectedTotal.WithLabelValues(owner.Kind).Inc()
+	log.FromContext(ctx).Info("Pods of owner are deleted repeatedly", "owner", owner.Kind+"/"+owner.Name,
+		"deletions", count, "window", r.ChurnWindow)
+
+	obj := &metav1.PartialObjectMetadata{
+		ObjectMeta: metav1.ObjectMeta{Namespace: pod.Namespace, Name: owner.Name, UID: owner.UID},
+	}
+	obj.SetGroupVersionKind(schema.FromAPIVersionAndKind(owner.APIVersion, owner.Kind))
+
+	message := "podbouncer deleted %d pods of this %s within %s"
+	if r.StopOnChurn {
+		message += ", further pods are not deleted until the rate drops"
+	}
+	r.Recorder.Eventf(obj, v1.EventTypeWarning, reasonPodChurn, message, count, owner.Kind, r.ChurnWindow)
+}
+
+// pruneChurn drops deletions which have left ChurnWindow every churnPruneInterval until ctx is done.
+func (r *PodReconciler) pruneChurn(ctx context.Context) error {
+	for {
+		select {
+		case <-r.Clock.After(churnPruneInterval):
+			r.churn.expire(r.Clock.Now(), r.ChurnWindow, r.ChurnThreshold)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// churnTracker counts pod deletions per owner in a sliding window. The zero value
+// is ready to use.
+type churnTracker struct {
+	mu     sync.Mutex
+	owners map[types.UID]*ownerChurn
+}
+
+type ownerChurn struct {
+	deletions []time.Time
+	churning  bool
+}
+
+// record adds a deletion of a pod of owner. It returns the number of deletions within
+// window and whether the owner has just crossed the threshold.
+func (t *churnTracker) record(owner types.UID, now time.Time, window time.Duration, threshold int) (int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.owners == nil {
+		t.owners = make(map[types.UID]*ownerChurn)
+	}
+
+	t.prune(now, window, threshold)
+
+	c, found := t.owners[owner]
+	if !found {
+		c = &ownerChurn{}
+		t.owners[owner] = c
+	}
+	c.deletions = append(c.deletions, now)
+
+	if c.churning || len(c.deletions) < threshold {
+		return len(c.deletions), false
+	}
+
+	c.churning = true
+	churningOwners.Inc()
+	return len(c.deletions), true
+}
+
+// churningUntil reports whether owner has at least threshold deletions within window.
+// The returned time is when enough deletions have left the window to drop below threshold.
+func (t *churnTracker) churningUntil(owner types.UID, now time.Time, window time.Duration, threshold int) (time.Time, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune(now, window, threshold)
+
+	c, found := t.owners[owner]
+	if !found || !c.churning {
+		return time.Time{}, false
+	}
+	return c.deletions[len(c.deletions)-threshold].Add(window), true
+}
+
+// expire drops deletions which are no longer within window.
+func (t *churnTracker) expire(now time.Time, window time.Duration, threshold int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune(now, window, threshold)
+}
+
+// prune drops deletions which are no longer within window. t.mu must be held.
+func (t *churnTracker) prune(now time.Time, window time.Duration, threshold int) {
+	for owner, c := range t.owners {
+		i := 0
+		for i < len(c.deletions) && now.Sub(c.deletions[i]) >= window {
+			i++
+		}
+		c.deletions = c.deletions[i:]
+
+		if c.churning && len(c.deletions) < threshold {
+			c.churning = false
+			churningOwners.Dec()
+		}
+		if len(c.deletions) == 0 {
+			delete(t.owners, owner)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_PodReconcilerChurn(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	newPod := func(name string) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "default",
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour)),
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "batch/v1",
+					Kind:       "CronJob",
+					Name:       "report",
+					UID:        "cronjob-uid",
+					Controller: ptr.To(true),
+				}},
+			},
+			Status: v1.PodStatus{Phase: v1.PodFailed},
+		}
+	}
+
+	pods := []*v1.Pod{newPod("report-a"), newPod("report-b"), newPod("report-c")}
+
+	clk := clocktesting.NewFakeClock(now)
+	recorder := record.NewFakeRecorder(10)
+	r := &PodReconciler{
+		Client:         fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(pods[0], pods[1], pods[2]).Build(),
+		Scheme:         scheme.Scheme,
+		Config:         NewPodReconcilerConfig(),
+		Clock:          clk,
+		Expiry:         NewExpiryScheduler(clk),
+		Recorder:       recorder,
+		ChurnWindow:    time.Hour,
+		ChurnThreshold: 2,
+		StopOnChurn:    true,
+	}
+
+	reconcile := func(pod *v1.Pod) bool {
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pod)})
+		require.NoError(t, err)
+		err = r.Get(context.Background(), client.ObjectKeyFromObject(pod), &v1.Pod{})
+		return apierrors.IsNotFound(err)
+	}
+
+	require.True(t, reconcile(pods[0]))
+	require.Empty(t, recorder.Events)
+
+	require.True(t, reconcile(pods[1]))
+	require.Equal(t, "Warning PodChurn podbouncer deleted 2 pods of this CronJob within 1h0m0s, "+
+		"further pods are not deleted until the rate drops", <-recorder.Events)
+
+	// Pods of the churning owner are kept and re-evaluated once the deletions leave the window
+	require.False(t, reconcile(pods[2]))
+	key := client.ObjectKeyFromObject(pods[2])
+	remaining, found := r.Expiry.Remaining(key)
+	require.True(t, found)
+	require.Equal(t, time.Hour, remaining)
+
+	clk.Step(time.Hour)
+	require.Equal(t, []types.NamespacedName{key}, r.Expiry.popExpired())
+	require.True(t, reconcile(pods[2]))
+	require.Empty(t, recorder.Events)
+}
+
+func Test_ChurnTracker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var tracker churnTracker
+
+	_, churning := tracker.churningUntil("a", now, time.Hour, 3)
+	require.False(t, churning)
+
+	count, crossed := tracker.record("a", now, time.Hour, 3)
+	require.Equal(t, 1, count)
+	require.False(t, crossed)
+
+	tracker.record("b", now, time.Hour, 3)
+	tracker.record("a", now.Add(20*time.Minute), time.Hour, 3)
+
+	// The threshold is only reported as crossed once
+	count, crossed = tracker.record("a", now.Add(40*time.Minute), time.Hour, 3)
+	require.Equal(t, 3, count)
+	require.True(t, crossed)
+	until, churning := tracker.churningUntil("a", now.Add(40*time.Minute), time.Hour, 3)
+	require.True(t, churning)
+	require.Equal(t, now.Add(time.Hour), until)
+	_, churning = tracker.churningUntil("b", now.Add(40*time.Minute), time.Hour, 3)
+	require.False(t, churning)
+
+	count, crossed = tracker.record("a", now.Add(50*time.Minute), time.Hour, 3)
+	require.Equal(t, 4, count)
+	require.False(t, crossed)
+
+	// Deletions slide out of the window
+	until, churning = tracker.churningUntil("a", now.Add(70*time.Minute), time.Hour, 3)
+	require.True(t, churning)
+	require.Equal(t, now.Add(80*time.Minute), until)
+	_, churning = tracker.churningUntil("a", now.Add(90*time.Minute), time.Hour, 3)
+	require.False(t, churning)
+
+	count, crossed = tracker.record("a", now.Add(90*time.Minute), time.Hour, 3)
+	require.Equal(t, 3, count)
+	require.True(t, crossed)
+}
+
+func Test_PodReconcilerPrunesChurn(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(now)
+	r := &PodReconciler{Clock: clk, ChurnWindow: time.Hour, ChurnThreshold: 2}
+
+	before := testutil.ToFloat64(churningOwners)
+	r.churn.record("a", now, time.Hour, 2)
+	r.churn.record("a", now, time.Hour, 2)
+	require.Equal(t, before+1, testutil.ToFloat64(churningOwners))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = r.pruneChurn(ctx) }()
+
+	// The owner stops churning without further deletions or checks
+	for i := 0; i < 60; i++ {
+		require.Eventually(t, clk.HasWaiters, time.Second, time.Millisecond)
+		clk.Step(churnPruneInterval)
+	}
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(churningOwners) == before
+	}, time.Second, time.Millisecond)
+}
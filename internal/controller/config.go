@@ -1,8 +1,15 @@
 package controller
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
 	"sync"
 	"time"
+
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 )
 
 // PodReconcilerConfig is the configuration object used by PodReconciler.
@@ -13,7 +20,13 @@ import (
 type PodReconcilerConfig struct {
 	sync.Mutex
 
-	maxPodAge time.Duration
+	maxPodAge   time.Duration
+	maintenance *MaintenanceWindows
+	paused      bool
+	forcePaused bool
+
+	// pending pauses destructive actions until a configuration has been applied
+	pending bool
 }
 
 func NewPodReconcilerConfig() *PodReconcilerConfig {
@@ -34,3 +47,237 @@ func (c *PodReconcilerConfig) MaxPodAge() time.Duration {
 
 	return c.maxPodAge
 }
+
+// SetMaintenanceWindows restricts deletions to the given windows. A nil value allows
+// deletions at any time.
+func (c *PodReconcilerConfig) SetMaintenanceWindows(w *MaintenanceWindows) {
+	c.Lock()
+	defer c.Unlock()
+	c.maintenance = w
+}
+
+// MaintenanceWindows returns the windows during which pods may be deleted, or nil
+// if deletions are allowed at any time.
+func (c *PodReconcilerConfig) MaintenanceWindows() *MaintenanceWindows {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.maintenance
+}
+
+// SetPaused pauses or resumes all destructive actions.
+func (c *PodReconcilerConfig) SetPaused(paused bool) {
+	c.Lock()
+	defer c.Unlock()
+	c.paused = paused
+	c.updatePausedGauge()
+}
+
+// ForcePause pauses all destructive actions permanently, regardless of SetPaused.
+func (c *PodReconcilerConfig) ForcePause() {
+	c.Lock()
+	defer c.Unlock()
+	c.forcePaused = true
+	c.updatePausedGauge()
+}
+
+// PauseUntilApplied pauses all destructive actions until a configuration source applies
+// a valid configuration. It is used if the configuration cannot be loaded on startup, so
+// pods are not deleted with the default configuration in the meantime.
+func (c *PodReconcilerConfig) PauseUntilApplied() {
+	c.Lock()
+	defer c.Unlock()
+	c.pending = true
+	c.updatePausedGauge()
+}
+
+// Paused reports whether all destructive actions are paused.
+func (c *PodReconcilerConfig) Paused() bool {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.paused || c.forcePaused || c.pending
+}
+
+// updatePausedGauge sets the paused gauge to the current state. Must be called with c locked.
+func (c *PodReconcilerConfig) updatePausedGauge() {
+	if c.paused || c.forcePaused || c.pending {
+		pausedGauge.Set(1)
+	} else {
+		pausedGauge.Set(0)
+	}
+}
+
+// ConfigGuardrails are hard limits for configuration values which protect the cluster
+// against typos in the configuration source.
+//
+// A maxPodAge below Floor is only accepted if the configuration explicitly sets
+// allowAggressive to "true". A maxPodAge above Ceiling is always rejected.
+// A zero Ceiling disables the upper limit.
+type ConfigGuardrails struct {
+	Floor   time.Duration
+	Ceiling time.Duration
+}
+
+// Validate returns an error if the guardrails contradict each other.
+func (g ConfigGuardrails) Validate() error {
+	if g.Floor < 0 || g.Ceiling < 0 {
+		return fmt.Errorf("floor %s and ceiling %s must not be negative", g.Floor, g.Ceiling)
+	}
+
+	if g.Ceiling > 0 && g.Floor > g.Ceiling {
+		return fmt.Errorf("floor %s must not be greater than ceiling %s", g.Floor, g.Ceiling)
+	}
+
+	return nil
+}
+
+// ValidateMaxPodAge returns an error if d must not be applied to a PodReconcilerConfig.
+func (g ConfigGuardrails) ValidateMaxPodAge(d time.Duration, allowAggressive bool) error {
+	if d < 0 {
+		return fmt.Errorf("maxPodAge must not be negative: %s", d)
+	}
+
+	if g.Ceiling > 0 && d > g.Ceiling {
+		return fmt.Errorf("maxPodAge %s exceeds the ceiling of %s", d, g.Ceiling)
+	}
+
+	if d < g.Floor && !allowAggressive {
+		return fmt.Errorf("maxPodAge %s is below the floor of %s, set allowAggressive to \"true\" to apply it anyway", d, g.Floor)
+	}
+
+	return nil
+}
+
+// configData contains the values parsed from a configuration source.
+type configData struct {
+	MaxPodAge          time.Duration
+	AllowAggressive    bool
+	MaintenanceWindows *MaintenanceWindows
+	Paused             bool
+}
+
+// apply applies all values of d to c at once, so that no reader sees a new maxPodAge
+// before a pause set along with it.
+func (c *PodReconcilerConfig) apply(d configData) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.maxPodAge = d.MaxPodAge
+	c.maintenance = d.MaintenanceWindows
+	c.paused = d.Paused
+	c.pending = false
+	c.updatePausedGauge()
+}
+
+// changedBy reports whether applying d would change any value of c.
+func (c *PodReconcilerConfig) changedBy(d configData) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.pending || c.maxPodAge != d.MaxPodAge || c.paused != d.Paused ||
+		c.maintenance.String() != d.MaintenanceWindows.String()
+}
+
+// parseConfigData parses the key / value pairs of a configuration source and
+// validates them against the given guardrails.
+func parseConfigData(data map[string]string, guardrails ConfigGuardrails) (configData, error) {
+	var parsed configData
+
+	maxPodAgeStr, found := data["maxPodAge"]
+	if !found {
+		return parsed, errors.New("missing maxPodAge property")
+	}
+
+	maxPodAge, err := time.ParseDuration(maxPodAgeStr)
+	if err != nil {
+		return parsed, fmt.Errorf("invalid maxPodAge property: %s", maxPodAgeStr)
+	}
+	parsed.MaxPodAge = maxPodAge
+
+	if allowAggressiveStr, found := data["allowAggressive"]; found {
+		allowAggressive, err := strconv.ParseBool(allowAggressiveStr)
+		if err != nil {
+			return parsed, fmt.Errorf("invalid allowAggressive property: %s", allowAggressiveStr)
+		}
+		parsed.AllowAggressive = allowAggressive
+	}
+
+	if parsed.Paused, err = parsePaused(data); err != nil {
+		return parsed, err
+	}
+
+	timeZone, hasTimeZone := data["maintenanceTimeZone"]
+	if windows, found := data["maintenanceWindows"]; found {
+		parsed.MaintenanceWindows, err = ParseMaintenanceWindows(windows, timeZone)
+		if err != nil {
+			return parsed, fmt.Errorf("invalid maintenanceWindows property: %w", err)
+		}
+	} else if hasTimeZone {
+		return parsed, errors.New("maintenanceTimeZone property requires maintenanceWindows")
+	}
+
+	if err := guardrails.ValidateMaxPodAge(parsed.MaxPodAge, parsed.AllowAggressive); err != nil {
+		return parsed, err
+	}
+
+	return parsed, nil
+}
+
+// parsePaused parses the paused property. It is false if the property is missing.
+func parsePaused(data map[string]string) (bool, error) {
+	pausedStr, found := data["paused"]
+	if !found {
+		return false, nil
+	}
+
+	paused, err := strconv.ParseBool(pausedStr)
+	if err != nil {
+		return false, fmt.Errorf("invalid paused property: %s", pausedStr)
+	}
+	return paused, nil
+}
+
+// applyPaused applies only the paused property of an otherwise invalid configuration
+// source, so that an error in another property cannot block pausing. It reports whether
+// the paused state of c changed.
+func (c *PodReconcilerConfig) applyPaused(data map[string]string) bool {
+	paused, err := parsePaused(data)
+	if err != nil {
+		return false
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if c.paused == paused {
+		return false
+	}
+	c.paused = paused
+	c.updatePausedGauge()
+	return true
+}
+
+// notifyConfigChanged sends a change event for obj without blocking.
+//
+// If the channel is full, a change event is already pending and the
+// receiver will pick up the latest configuration anyway.
+func notifyConfigChanged(changes chan<- event.GenericEvent, obj client.Object) {
+	if changes == nil {
+		return
+	}
+
+	select {
+	case changes <- event.GenericEvent{Object: obj}:
+	default:
+	}
+}
+
+// recordConfigUpdate sets the last update gauge to the current time of clk, or of the
+// real clock if clk is nil. Configuration sources call it after applying a change.
+func recordConfigUpdate(clk clock.PassiveClock) {
+	if clk == nil {
+		clk = clock.RealClock{}
+	}
+	configLastUpdate.Set(float64(clk.Now().UnixNano()) / 1e9)
+}
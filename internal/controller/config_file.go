@@ -0,0 +1,191 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/fsnotify/fsnotify"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+)
+
+// FileConfigSource configures a PodReconcilerConfig object from a file.
+//
+// It is an alternative to ConfigMapReconciler. The file contains the same keys as the
+// data of the ConfigMap, either as a flat YAML mapping or as a complete ConfigMap manifest.
+// The file is watched and changes are applied without restarting the manager.
+type FileConfigSource struct {
+	Path string
+
+	Config *PodReconcilerConfig
+
+	// Guardrails are the hard limits configuration values are validated against
+	// before they are applied to Config.
+	Guardrails ConfigGuardrails
+
+	// Changes receives an event every time Config has been updated.
+	Changes chan<- event.GenericEvent
+
+	// Observers are notified every time Config has been updated after the initial load.
+	Observers []ConfigObserver
+
+	// Clock is used to record the time of updates. The real clock is used if it is nil.
+	Clock clock.PassiveClock
+
+	// content is the file content which was last applied to Config
+	content []byte
+}
+
+// Load reads the file and applies its values to Config.
+//
+// Call Load before starting the manager to fail early on a broken configuration.
+func (s *FileConfigSource) Load() error {
+	_, err := s.load()
+	return err
+}
+
+// load reads the file and applies it to Config. It returns false if the file content
+// has not changed since it was last applied.
+func (s *FileConfigSource) load() (bool, error) {
+	content, err := os.ReadFile(s.Path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if s.content != nil && bytes.Equal(content, s.content) {
+		return false, nil
+	}
+
+	raw, err := parseConfigFile(content)
+	if err != nil {
+		return false, fmt.Errorf("invalid config file %s: %w", s.Path, err)
+	}
+
+	data, err := parseConfigData(raw, s.Guardrails)
+	if err != nil {
+		if s.Config.applyPaused(raw) {
+			recordConfigUpdate(s.Clock)
+			notifyConfigChanged(s.Changes, &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: filepath.Base(s.Path)}})
+			return false, fmt.Errorf("invalid config file %s, only paused is updated: %w", s.Path, err)
+		}
+		return false, fmt.Errorf("invalid config file %s: %w", s.Path, err)
+	}
+
+	s.Config.apply(data)
+	recordConfigUpdate(s.Clock)
+	s.content = content
+
+	return true, nil
+}
+
+// Start watches the file and applies every change to Config until ctx is done.
+//
+// The parent directory is watched instead of the file itself, so that atomic
+// replacements (e.g. the symlink swap used for mounted ConfigMaps) are detected.
+func (s *FileConfigSource) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("config-file").WithValues("path", s.Path)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config file watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(filepath.Dir(s.Path)); err != nil {
+		return fmt.Errorf("failed to watch config file: %w", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Error(err, "Config file watcher failed")
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			oldMaxPodAge := s.Config.MaxPodAge()
+
+			changed, err := s.load()
+			if err != nil {
+				// Log error but keep watching - the error must be fixed manually
+				logger.Error(err, "Configuration will not be updated")
+				continue
+			}
+			if changed {
+				logger.Info("Configuration updated", "newMaxPodAge", s.Config.MaxPodAge(), "currentMaxPodAge", oldMaxPodAge)
+				notifyConfigChanged(s.Changes, &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: filepath.Base(s.Path)}})
+				observeConfigUpdate(ctx, s.Observers, ConfigUpdate{
+					Source:            "file:" + s.Path,
+					MaxPodAge:         s.Config.MaxPodAge(),
+					PreviousMaxPodAge: oldMaxPodAge,
+				})
+			}
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Every replica
+// must know the current configuration.
+func (s *FileConfigSource) NeedLeaderElection() bool {
+	return false
+}
+
+// parseConfigFile returns the configuration key / value pairs of a config file.
+func parseConfigFile(content []byte) (map[string]string, error) {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+
+	// Allow reusing ConfigMap manifests
+	if kind, _ := doc["kind"].(string); kind == "ConfigMap" {
+		data, _ := doc["data"].(map[string]interface{})
+		doc = data
+	}
+
+	result := make(map[string]string, len(doc))
+	for key, value := range doc {
+		switch v := value.(type) {
+		case string:
+			result[key] = v
+		case bool:
+			result[key] = strconv.FormatBool(v)
+		case float64:
+			result[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return nil, fmt.Errorf("property %s must be a scalar value", key)
+		}
+	}
+
+	return result, nil
+}
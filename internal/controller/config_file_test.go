@@ -0,0 +1,113 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_FileConfigSourceLoad(t *testing.T) {
+	t.Run("loads flat mapping", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("maxPodAge: 2h\n"), 0o600))
+
+		s := &FileConfigSource{Path: path, Config: NewPodReconcilerConfig()}
+		require.NoError(t, s.Load())
+		require.Equal(t, 2*time.Hour, s.Config.MaxPodAge())
+	})
+
+	t.Run("loads ConfigMap manifest", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		manifest := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: config\ndata:\n  maxPodAge: 30m\n"
+		require.NoError(t, os.WriteFile(path, []byte(manifest), 0o600))
+
+		s := &FileConfigSource{Path: path, Config: NewPodReconcilerConfig()}
+		require.NoError(t, s.Load())
+		require.Equal(t, 30*time.Minute, s.Config.MaxPodAge())
+	})
+
+	t.Run("accepts unquoted booleans", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("maxPodAge: 1s\nallowAggressive: true\n"), 0o600))
+
+		s := &FileConfigSource{Path: path, Config: NewPodReconcilerConfig(), Guardrails: ConfigGuardrails{Floor: time.Minute}}
+		require.NoError(t, s.Load())
+		require.Equal(t, time.Second, s.Config.MaxPodAge())
+	})
+
+	t.Run("rejects values outside of guardrails", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("maxPodAge: 1s\n"), 0o600))
+
+		s := &FileConfigSource{Path: path, Config: NewPodReconcilerConfig(), Guardrails: ConfigGuardrails{Floor: time.Minute}}
+		require.Error(t, s.Load())
+		require.Equal(t, time.Hour, s.Config.MaxPodAge())
+	})
+
+	t.Run("rejects nested values", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("maxPodAge:\n  hours: 1\n"), 0o600))
+
+		s := &FileConfigSource{Path: path, Config: NewPodReconcilerConfig()}
+		require.Error(t, s.Load())
+	})
+}
+
+func Test_FileConfigSourceReloadsOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("maxPodAge: 2h\n"), 0o600))
+
+	updates := &recordingConfigObserver{}
+	s := &FileConfigSource{Path: path, Config: NewPodReconcilerConfig(), Observers: []ConfigObserver{updates}}
+	require.NoError(t, s.Load())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error)
+	go func() { done <- s.Start(ctx) }()
+
+	require.Eventually(t, func() bool {
+		// Rewrite the file until the watcher has been set up and picked up the change
+		_ = os.WriteFile(path, []byte("maxPodAge: 3h\n"), 0o600)
+		return s.Config.MaxPodAge() == 3*time.Hour
+	}, 5*time.Second, 50*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+
+	require.Equal(t, []ConfigUpdate{{Source: "file:" + path, MaxPodAge: 3 * time.Hour, PreviousMaxPodAge: 2 * time.Hour}}, updates.updates)
+}
+
+func Test_FileConfigSourceAppliesPausedOfInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("maxPodAge: 2h\n"), 0o600))
+
+	s := &FileConfigSource{Path: path, Config: NewPodReconcilerConfig()}
+	require.NoError(t, s.Load())
+
+	require.NoError(t, os.WriteFile(path, []byte("maxPodAge: 1 minute\npaused: \"true\"\n"), 0o600))
+	require.ErrorContains(t, s.Load(), "only paused is updated")
+	require.True(t, s.Config.Paused())
+	require.Equal(t, 2*time.Hour, s.Config.MaxPodAge())
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_ConfigGuardrailsValidateMaxPodAge(t *testing.T) {
+	type Test struct {
+		MaxPodAge       time.Duration
+		AllowAggressive bool
+		ExpectError     bool
+	}
+
+	guardrails := ConfigGuardrails{Floor: time.Minute, Ceiling: 24 * time.Hour}
+
+	tests := []Test{
+		{MaxPodAge: time.Hour, ExpectError: false},
+		{MaxPodAge: time.Minute, ExpectError: false},
+		{MaxPodAge: 24 * time.Hour, ExpectError: false},
+		{MaxPodAge: 0, ExpectError: true},
+		{MaxPodAge: 0, AllowAggressive: true, ExpectError: false},
+		{MaxPodAge: time.Second, ExpectError: true},
+		{MaxPodAge: time.Second, AllowAggressive: true, ExpectError: false},
+		{MaxPodAge: -time.Hour, ExpectError: true},
+		{MaxPodAge: -time.Hour, AllowAggressive: true, ExpectError: true},
+		{MaxPodAge: 25 * time.Hour, ExpectError: true},
+		{MaxPodAge: 25 * time.Hour, AllowAggressive: true, ExpectError: true},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("returns expected error %d", i), func(t *testing.T) {
+			err := guardrails.ValidateMaxPodAge(test.MaxPodAge, test.AllowAggressive)
+			if test.ExpectError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+
+	t.Run("zero ceiling disables the upper limit", func(t *testing.T) {
+		require.NoError(t, ConfigGuardrails{}.ValidateMaxPodAge(10000*time.Hour, false))
+	})
+}
+
+func Test_ParseConfigData(t *testing.T) {
+	type Test struct {
+		Data        map[string]string
+		Expected    configData
+		ExpectError bool
+	}
+
+	guardrails := ConfigGuardrails{Floor: time.Minute}
+
+	tests := []Test{
+		{
+			Data:     map[string]string{"maxPodAge": "1h"},
+			Expected: configData{MaxPodAge: time.Hour},
+		},
+		{
+			Data:     map[string]string{"maxPodAge": "0s", "allowAggressive": "true"},
+			Expected: configData{MaxPodAge: 0, AllowAggressive: true},
+		},
+		{
+			Data:        map[string]string{"maxPodAge": "0s"},
+			ExpectError: true,
+		},
+		{
+			Data:        map[string]string{"maxPodAge": "0s", "allowAggressive": "yes please"},
+			ExpectError: true,
+		},
+		{
+			Data:        map[string]string{"maxPodAge": "one hour"},
+			ExpectError: true,
+		},
+		{
+			Data:        map[string]string{},
+			ExpectError: true,
+		},
+		{
+			Data:        map[string]string{"maxPodAge": "1h", "maintenanceWindows": "0 9 * * * eight hours"},
+			ExpectError: true,
+		},
+		{
+			Data:        map[string]string{"maxPodAge": "1h", "maintenanceWindows": "0 9 * * * 8h", "maintenanceTimeZone": "Mars/Olympus"},
+			ExpectError: true,
+		},
+		{
+			Data:        map[string]string{"maxPodAge": "1h", "maintenanceTimeZone": "Europe/Berlin"},
+			ExpectError: true,
+		},
+		{
+			Data:     map[string]string{"maxPodAge": "1h", "paused": "true"},
+			Expected: configData{MaxPodAge: time.Hour, Paused: true},
+		},
+		{
+			Data:        map[string]string{"maxPodAge": "1h", "paused": "maybe"},
+			ExpectError: true,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("returns expected value %d", i), func(t *testing.T) {
+			parsed, err := parseConfigData(test.Data, guardrails)
+			if test.ExpectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.Expected, parsed)
+		})
+	}
+}
+
+func Test_ConfigSourcesRecordLastUpdate(t *testing.T) {
+	createdAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(createdAt)
+	configLastUpdate.Set(0)
+
+	// Configurations which are not applied by a source are not recorded
+	c := NewPodReconcilerConfig()
+	c.SetMaxPodAge(2 * time.Hour)
+	require.Zero(t, testutil.ToFloat64(configLastUpdate))
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("maxPodAge: 2h\n"), 0o600))
+	require.NoError(t, (&FileConfigSource{Path: path, Config: c, Clock: clk}).Load())
+	require.Equal(t, float64(createdAt.Unix()), testutil.ToFloat64(configLastUpdate))
+
+	clk.Step(time.Minute)
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: configMapObjectNamespace, Name: configMapObjectName},
+		Data:       map[string]string{"maxPodAge": "3h"},
+	}
+	r := &ConfigMapReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(configMap).Build(),
+		Scheme: scheme.Scheme,
+		Config: c,
+		Clock:  clk,
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(configMap)}
+	_, err := r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, float64(createdAt.Add(time.Minute).Unix()), testutil.ToFloat64(configLastUpdate))
+
+	// Reconciling an unchanged ConfigMap is not an update
+	clk.Step(time.Minute)
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, float64(createdAt.Add(time.Minute).Unix()), testutil.ToFloat64(configLastUpdate))
+}
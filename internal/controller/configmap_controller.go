@@ -18,11 +18,10 @@ package controller
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"time"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/utils/clock"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
@@ -41,6 +40,22 @@ type ConfigMapReconciler struct {
 	Scheme *runtime.Scheme
 
 	Config *PodReconcilerConfig
+
+	// Guardrails are the hard limits configuration values are validated against
+	// before they are applied to Config.
+	Guardrails ConfigGuardrails
+
+	// Options configure the throughput of the controller.
+	Options ReconcilerOptions
+
+	// Changes receives an event every time Config has been updated.
+	Changes chan<- event.GenericEvent
+
+	// Observers are notified every time Config has been updated.
+	Observers []ConfigObserver
+
+	// Clock is used to record the time of updates. The real clock is used if it is nil.
+	Clock clock.PassiveClock
 }
 
 const (
@@ -68,31 +83,72 @@ func (r *ConfigMapReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	}
 
 	// Retrieve config value
-	data := config.Data
-
-	if maxPodAgeStr, found := data["maxPodAge"]; !found {
+	data, err := parseConfigData(config.Data, r.Guardrails)
+	if err != nil {
 		// Log error but do not requeue - the error must be fixed manually
-		err := errors.New("missing maxPodAge property in ConfigMap")
-		logger.Error(err, "Configuration will not be updated")
-		return ctrl.Result{}, nil
-	} else {
-		maxPodAge, err := time.ParseDuration(maxPodAgeStr)
-
-		if err != nil {
-			// Log error but do not requeue - the error must be fixed manually
-			err := fmt.Errorf("invalid maxPodAge property in ConfigMap: %s", maxPodAgeStr)
-			logger.Error(err, "Configuration will not be updated")
-			return ctrl.Result{}, nil
+		logger.Error(fmt.Errorf("invalid ConfigMap: %w", err), "Configuration will not be updated")
+		if r.Config.applyPaused(config.Data) {
+			logger.Info("Paused state updated despite the invalid ConfigMap", "paused", r.Config.Paused())
+			recordConfigUpdate(r.Clock)
+			notifyConfigChanged(r.Changes, &config)
 		}
+		return ctrl.Result{}, nil
+	}
 
-		oldMaxPodAge := r.Config.MaxPodAge()
+	// Resyncs and unrelated ConfigMap changes do not change the configuration
+	if !r.Config.changedBy(data) {
+		return ctrl.Result{}, nil
+	}
 
-		r.Config.SetMaxPodAge(maxPodAge)
+	oldMaxPodAge := r.Config.MaxPodAge()
 
-		logger.Info("Configuration updated", "newMaxPodAge", maxPodAge, "currentMaxPodAge", oldMaxPodAge)
+	r.Config.apply(data)
+	recordConfigUpdate(r.Clock)
 
-		return ctrl.Result{}, nil
+	logger.Info("Configuration updated", "newMaxPodAge", data.MaxPodAge, "currentMaxPodAge", oldMaxPodAge,
+		"maintenanceWindows", data.MaintenanceWindows.String())
+
+	notifyConfigChanged(r.Changes, &config)
+	observeConfigUpdate(ctx, r.Observers, ConfigUpdate{
+		Source:            "configmap/" + req.NamespacedName.String(),
+		MaxPodAge:         data.MaxPodAge,
+		PreviousMaxPodAge: oldMaxPodAge,
+	})
+
+	return ctrl.Result{}, nil
+}
+
+// Load applies the ConfigMap to Config once, reading it with c.
+//
+// Call Load before starting the manager, so pods are not evaluated with the default
+// configuration until the caches of the reconciler have synced.
+func (r *ConfigMapReconciler) Load(ctx context.Context, c client.Reader) error {
+	if err := LoadConfigMap(ctx, c, r.Config, r.Guardrails); err != nil {
+		return err
 	}
+	recordConfigUpdate(r.Clock)
+	return nil
+}
+
+// LoadConfigMap applies the values of the podbouncer ConfigMap to config once.
+//
+// It is used by commands which do not run a ConfigMapReconciler.
+func LoadConfigMap(ctx context.Context, c client.Reader, config *PodReconcilerConfig, guardrails ConfigGuardrails) error {
+	key := client.ObjectKey{Namespace: configMapObjectNamespace, Name: configMapObjectName}
+
+	var configMap v1.ConfigMap
+	if err := c.Get(ctx, key, &configMap); err != nil {
+		return fmt.Errorf("failed to get ConfigMap %s: %w", key, err)
+	}
+
+	data, err := parseConfigData(configMap.Data, guardrails)
+	if err != nil {
+		return fmt.Errorf("invalid ConfigMap %s: %w", key, err)
+	}
+
+	config.apply(data)
+
+	return nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -116,9 +172,12 @@ func (r *ConfigMapReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		},
 	}
 
+	maxConcurrentReconciles.WithLabelValues("configmap").Set(float64(r.Options.MaxConcurrentReconciles))
+
 	return ctrl.NewControllerManagedBy(mgr).
 		Watches(&v1.ConfigMap{}, &handler.EnqueueRequestForObject{}).
 		WithEventFilter(p).
+		WithOptions(r.Options.controllerOptions()).
 		Named("configmap").
 		Complete(r)
 }
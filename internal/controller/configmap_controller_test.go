@@ -17,7 +17,20 @@ limitations under the License.
 package controller
 
 import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
 	. "github.com/onsi/ginkgo/v2"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 )
 
 var _ = Describe("ConfigMap Controller", func() {
@@ -30,3 +43,122 @@ var _ = Describe("ConfigMap Controller", func() {
 		})
 	})
 })
+
+func Test_ConfigMapReconcilerAppliesGuardrails(t *testing.T) {
+	type Test struct {
+		Data              map[string]string
+		ExpectedMaxPodAge time.Duration
+		ExpectChange      bool
+	}
+
+	tests := []Test{
+		{
+			Data:              map[string]string{"maxPodAge": "2h"},
+			ExpectedMaxPodAge: 2 * time.Hour,
+			ExpectChange:      true,
+		},
+		{
+			Data:              map[string]string{"maxPodAge": "60m"},
+			ExpectedMaxPodAge: time.Hour,
+		},
+		{
+			Data:              map[string]string{"maxPodAge": "0s"},
+			ExpectedMaxPodAge: time.Hour,
+		},
+		{
+			Data:              map[string]string{"maxPodAge": "-5m", "allowAggressive": "true"},
+			ExpectedMaxPodAge: time.Hour,
+		},
+		{
+			Data:              map[string]string{"maxPodAge": "10s", "allowAggressive": "true"},
+			ExpectedMaxPodAge: 10 * time.Second,
+			ExpectChange:      true,
+		},
+		{
+			Data:              map[string]string{"maxPodAge": "48h"},
+			ExpectedMaxPodAge: time.Hour,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("applies expected maxPodAge %d", i), func(t *testing.T) {
+			configMap := &v1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: configMapObjectNamespace, Name: configMapObjectName},
+				Data:       test.Data,
+			}
+
+			changes := make(chan event.GenericEvent, 1)
+			updates := &recordingConfigObserver{}
+
+			r := &ConfigMapReconciler{
+				Client:     fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(configMap).Build(),
+				Scheme:     scheme.Scheme,
+				Config:     NewPodReconcilerConfig(),
+				Guardrails: ConfigGuardrails{Floor: time.Minute, Ceiling: 24 * time.Hour},
+				Changes:    changes,
+				Observers:  []ConfigObserver{updates},
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: configMapObjectNamespace, Name: configMapObjectName}}
+			result, err := r.Reconcile(context.Background(), req)
+			require.NoError(t, err)
+			require.Equal(t, ctrl.Result{}, result)
+			require.Equal(t, test.ExpectedMaxPodAge, r.Config.MaxPodAge())
+			require.Equal(t, test.ExpectChange, len(changes) == 1, "unexpected change event")
+
+			if test.ExpectChange {
+				require.Equal(t, []ConfigUpdate{{
+					Source:            "configmap/podbouncer-system/podbouncer-config",
+					MaxPodAge:         test.ExpectedMaxPodAge,
+					PreviousMaxPodAge: time.Hour,
+				}}, updates.updates)
+			} else {
+				require.Empty(t, updates.updates)
+			}
+
+			// Reconciling the unchanged ConfigMap again, e.g. on a resync, is not reported
+			_, err = r.Reconcile(context.Background(), req)
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(updates.updates), 1)
+		})
+	}
+}
+
+type recordingConfigObserver struct {
+	updates []ConfigUpdate
+}
+
+func (o *recordingConfigObserver) ObserveConfigUpdate(_ context.Context, update ConfigUpdate) {
+	o.updates = append(o.updates, update)
+}
+
+func Test_ConfigMapReconcilerAppliesPausedOfInvalidConfigMap(t *testing.T) {
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: configMapObjectNamespace, Name: configMapObjectName},
+		Data:       map[string]string{"maxPodAge": "2 hours", "paused": "true"},
+	}
+
+	changes := make(chan event.GenericEvent, 1)
+	r := &ConfigMapReconciler{
+		Client:  fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(configMap).Build(),
+		Scheme:  scheme.Scheme,
+		Config:  NewPodReconcilerConfig(),
+		Changes: changes,
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: configMapObjectNamespace, Name: configMapObjectName}}
+	_, err := r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.True(t, r.Config.Paused())
+	require.Equal(t, time.Hour, r.Config.MaxPodAge(), "invalid values must not be applied")
+	require.Len(t, changes, 1)
+
+	// Resuming works the same way
+	<-changes
+	configMap.Data["paused"] = "false"
+	require.NoError(t, r.Update(context.Background(), configMap))
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.False(t, r.Config.Paused())
+	require.Len(t, changes, 1)
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed standard cron expression with the fields minute, hour,
+// day of month, month and day of week.
+//
+// Every field accepts "*", single values, ranges ("1-5"), steps ("*/15", "0-30/10")
+// and comma-separated lists of those. Months and days of week may also be given by
+// their three-letter English names, and both 0 and 7 denote Sunday. Like in cron, a
+// day matches if either the day of month or the day of week matches when both are
+// restricted.
+type cronSchedule struct {
+	minute, hour, dom, month, dow cronField
+}
+
+// cronField is a bit set of the values matched by a field of a cron expression.
+type cronField struct {
+	bits uint64
+	star bool
+}
+
+func (f cronField) has(v int) bool {
+	return f.bits&(1<<uint(v)) != 0
+}
+
+type cronBounds struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var (
+	cronMinute = cronBounds{name: "minute", min: 0, max: 59}
+	cronHour   = cronBounds{name: "hour", min: 0, max: 23}
+	cronDom    = cronBounds{name: "day of month", min: 1, max: 31}
+	cronMonth  = cronBounds{name: "month", min: 1, max: 12, names: []string{
+		"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	cronDow = cronBounds{name: "day of week", min: 0, max: 7, names: []string{
+		"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+// parseCronSchedule parses a cron expression with five fields.
+func parseCronSchedule(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields, got %d", spec, len(fields))
+	}
+
+	var s cronSchedule
+	targets := []*cronField{&s.minute, &s.hour, &s.dom, &s.month, &s.dow}
+	for i, bounds := range []cronBounds{cronMinute, cronHour, cronDom, cronMonth, cronDow} {
+		field, err := parseCronField(fields[i], bounds)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", spec, err)
+		}
+		*targets[i] = field
+	}
+
+	// Sunday may be given as 7
+	if s.dow.has(7) {
+		s.dow.bits |= 1
+	}
+
+	return &s, nil
+}
+
+func parseCronField(field string, bounds cronBounds) (cronField, error) {
+	var f cronField
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step <= 0 {
+				return f, fmt.Errorf("invalid step %q in %s field", stepStr, bounds.name)
+			}
+		}
+
+		var from, to int
+		switch {
+		case rng == "*":
+			from, to = bounds.min, bounds.max
+			f.star = f.star || !hasStep
+		case strings.Contains(rng, "-"):
+			fromStr, toStr, _ := strings.Cut(rng, "-")
+			var err error
+			if from, err = bounds.value(fromStr); err != nil {
+				return f, err
+			}
+			if to, err = bounds.value(toStr); err != nil {
+				return f, err
+			}
+			if from > to {
+				return f, fmt.Errorf("invalid range %q in %s field", rng, bounds.name)
+			}
+		default:
+			var err error
+			if from, err = bounds.value(rng); err != nil {
+				return f, err
+			}
+			to = from
+			// Like in cron, "5/15" means every 15 starting at 5
+			if hasStep {
+				to = bounds.max
+			}
+		}
+
+		for v := from; v <= to; v += step {
+			f.bits |= 1 << uint(v)
+		}
+	}
+
+	return f, nil
+}
+
+// value parses a single number or name of a field.
+func (b cronBounds) value(s string) (int, error) {
+	for i, name := range b.names {
+		if strings.EqualFold(s, name) {
+			return i + b.min, nil
+		}
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < b.min || v > b.max {
+		return 0, fmt.Errorf("invalid value %q in %s field, must be between %d and %d", s, b.name, b.min, b.max)
+	}
+	return v, nil
+}
+
+// matchesDay reports whether the date of t matches the day of month and day of week fields.
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	dom := s.dom.has(t.Day())
+	dow := s.dow.has(int(t.Weekday()))
+	if s.dom.star || s.dow.star {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// cronSearchLimit bounds the search for the next activation, so expressions which
+// never match (e.g. "0 0 30 2 *") terminate.
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// next returns the first activation strictly after t in the location of t.
+// The returned time is zero if the schedule does not activate within cronSearchLimit.
+func (s *cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	limit := t.Add(cronSearchLimit)
+
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for t.Before(limit) {
+		year, month, day := t.Date()
+
+		switch {
+		case !s.month.has(int(month)):
+			t = later(t, time.Date(year, month+1, 1, 0, 0, 0, 0, loc))
+		case !s.matchesDay(t):
+			t = later(t, time.Date(year, month, day+1, 0, 0, 0, 0, loc))
+		case !s.hour.has(t.Hour()):
+			// Skip to the next hour in absolute time, wall clock hours may repeat or be skipped
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+		case !s.minute.has(t.Minute()):
+			if next := bits.TrailingZeros64(s.minute.bits >> uint(t.Minute())); next < 64 {
+				t = t.Add(time.Duration(next) * time.Minute)
+			} else {
+				t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			}
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// later returns next if it is after t. Otherwise, e.g. if a daylight saving time
+// transition moved a wall clock time backwards, it returns t advanced by an hour.
+func later(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return t.Add(time.Hour)
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CronScheduleNext(t *testing.T) {
+	type Test struct {
+		Spec     string
+		From     time.Time
+		Expected time.Time
+	}
+
+	// 2024-01-01 is a Monday
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []Test{
+		{Spec: "* * * * *", From: at(1, 1, 12, 0), Expected: at(1, 1, 12, 1)},
+		{Spec: "*/15 * * * *", From: at(1, 1, 12, 1), Expected: at(1, 1, 12, 15)},
+		{Spec: "5/20 * * * *", From: at(1, 1, 12, 30), Expected: at(1, 1, 12, 45)},
+		{Spec: "0 9 * * *", From: at(1, 1, 9, 0), Expected: at(1, 2, 9, 0)},
+		{Spec: "0 9 * * mon-fri", From: at(1, 5, 10, 0), Expected: at(1, 8, 9, 0)},
+		{Spec: "0 0 * * 7", From: at(1, 1, 0, 0), Expected: at(1, 7, 0, 0)},
+		{Spec: "30 22 1 * *", From: at(1, 1, 23, 0), Expected: at(2, 1, 22, 30)},
+		{Spec: "0 0 29 feb *", From: at(3, 1, 0, 0), Expected: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{Spec: "0,30 8-10 * Dec *", From: at(1, 1, 0, 0), Expected: at(12, 1, 8, 0)},
+		// Either day matches if both are restricted
+		{Spec: "0 0 15 * sat", From: at(1, 1, 0, 0), Expected: at(1, 6, 0, 0)},
+		{Spec: "0 0 30 2 *", From: at(1, 1, 0, 0), Expected: time.Time{}},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("returns expected time %d", i), func(t *testing.T) {
+			s, err := parseCronSchedule(test.Spec)
+			require.NoError(t, err)
+			require.Equal(t, test.Expected, s.next(test.From))
+		})
+	}
+}
+
+func Test_CronScheduleNextAcrossDaylightSavingTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	s, err := parseCronSchedule("30 2 * * *")
+	require.NoError(t, err)
+
+	// 02:30 does not exist on 2024-03-31, the clocks jump from 02:00 to 03:00
+	next := s.next(time.Date(2024, 3, 30, 12, 0, 0, 0, berlin))
+	require.Equal(t, time.Date(2024, 4, 1, 2, 30, 0, 0, berlin), next)
+
+	// 02:30 exists twice on 2024-10-27
+	next = s.next(time.Date(2024, 10, 27, 0, 0, 0, 0, berlin))
+	require.Equal(t, time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC), next.UTC())
+}
+
+func Test_ParseCronScheduleRejectsInvalidExpressions(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * foo *",
+	} {
+		_, err := parseCronSchedule(spec)
+		require.Error(t, err, spec)
+	}
+}
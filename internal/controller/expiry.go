@@ -0,0 +1,246 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// ExpiryScheduler tracks the expiry deadlines of pods waiting to reach their max age
+// and emits an event for every pod once its deadline has passed.
+//
+// Deadlines are kept in a min-heap, so the scheduler only ever waits for the
+// earliest deadline. Use NewExpiryScheduler to create instances.
+type ExpiryScheduler struct {
+	clock clock.Clock
+
+	// gauge is set to the number of tracked pods
+	gauge prometheus.Gauge
+
+	mu    sync.Mutex
+	items expiryHeap
+	index map[types.NamespacedName]*expiryItem
+
+	// wake signals the scheduler loop that the earliest deadline may have changed
+	wake   chan struct{}
+	events chan event.GenericEvent
+}
+
+func NewExpiryScheduler(clk clock.Clock) *ExpiryScheduler {
+	return newExpiryScheduler(clk, scheduledPods)
+}
+
+func newExpiryScheduler(clk clock.Clock, gauge prometheus.Gauge) *ExpiryScheduler {
+	return &ExpiryScheduler{
+		clock:  clk,
+		gauge:  gauge,
+		index:  make(map[types.NamespacedName]*expiryItem),
+		wake:   make(chan struct{}, 1),
+		events: make(chan event.GenericEvent),
+	}
+}
+
+// Schedule sets the expiry deadline of the given pod, replacing any previous deadline.
+// It reports whether the pod was not tracked before or its deadline has changed.
+func (s *ExpiryScheduler) Schedule(key types.NamespacedName, deadline time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if item, found := s.index[key]; found {
+		if item.deadline.Equal(deadline) {
+			return false
+		}
+		item.deadline = deadline
+		heap.Fix(&s.items, item.index)
+	} else {
+		item := &expiryItem{key: key, deadline: deadline}
+		heap.Push(&s.items, item)
+		s.index[key] = item
+	}
+
+	s.gauge.Set(float64(len(s.items)))
+	s.notify()
+
+	return true
+}
+
+// Remove stops tracking the given pod.
+func (s *ExpiryScheduler) Remove(key types.NamespacedName) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, found := s.index[key]
+	if !found {
+		return
+	}
+
+	heap.Remove(&s.items, item.index)
+	delete(s.index, key)
+
+	s.gauge.Set(float64(len(s.items)))
+	s.notify()
+}
+
+// Remaining returns the time left until the deadline of the given pod.
+// The returned bool is false if the pod is not tracked.
+func (s *ExpiryScheduler) Remaining(key types.NamespacedName) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, found := s.index[key]
+	if !found {
+		return 0, false
+	}
+
+	return item.deadline.Sub(s.clock.Now()), true
+}
+
+// Requests returns a reconcile request for every tracked pod.
+func (s *ExpiryScheduler) Requests() []ctrl.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	requests := make([]ctrl.Request, 0, len(s.items))
+	for _, item := range s.items {
+		requests = append(requests, ctrl.Request{NamespacedName: item.key})
+	}
+	return requests
+}
+
+// Events returns the channel on which an event is emitted for every expired pod.
+func (s *ExpiryScheduler) Events() <-chan event.GenericEvent {
+	return s.events
+}
+
+// Start emits events for expired pods until ctx is done.
+func (s *ExpiryScheduler) Start(ctx context.Context) error {
+	for {
+		for _, key := range s.popExpired() {
+			pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}}
+			select {
+			case s.events <- event.GenericEvent{Object: pod}:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		var timer clock.Timer
+		var expired <-chan time.Time
+		if wait, ok := s.nextWait(); ok {
+			timer = s.clock.NewTimer(wait)
+			expired = timer.C()
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-s.wake:
+		case <-expired:
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// popExpired removes and returns all pods whose deadline has passed.
+func (s *ExpiryScheduler) popExpired() []types.NamespacedName {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+
+	var expired []types.NamespacedName
+	for len(s.items) > 0 && !s.items[0].deadline.After(now) {
+		item := heap.Pop(&s.items).(*expiryItem)
+		delete(s.index, item.key)
+		expired = append(expired, item.key)
+	}
+
+	s.gauge.Set(float64(len(s.items)))
+	return expired
+}
+
+// nextWait returns the time until the earliest deadline. The returned bool is false
+// if no pod is tracked.
+func (s *ExpiryScheduler) nextWait() (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.items) == 0 {
+		return 0, false
+	}
+
+	return s.items[0].deadline.Sub(s.clock.Now()), true
+}
+
+// notify wakes up the scheduler loop without blocking. Must be called with mu held.
+func (s *ExpiryScheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+type expiryItem struct {
+	key      types.NamespacedName
+	deadline time.Time
+	index    int
+}
+
+// expiryHeap implements heap.Interface ordered by the earliest deadline.
+type expiryHeap []*expiryItem
+
+func (h expiryHeap) Len() int { return len(h) }
+
+func (h expiryHeap) Less(i, j int) bool { return h[i].deadline.Before(h[j].deadline) }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x any) {
+	item := x.(*expiryItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *expiryHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	clocktesting "k8s.io/utils/clock/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func Test_ExpirySchedulerRemaining(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(now)
+	s := NewExpiryScheduler(clk)
+
+	key := types.NamespacedName{Namespace: "default", Name: "a"}
+
+	_, found := s.Remaining(key)
+	require.False(t, found)
+
+	require.True(t, s.Schedule(key, now.Add(time.Hour)), "new pods should be reported as changed")
+	require.False(t, s.Schedule(key, now.Add(time.Hour)), "an unchanged deadline should not be reported")
+	remaining, found := s.Remaining(key)
+	require.True(t, found)
+	require.Equal(t, time.Hour, remaining)
+
+	clk.Step(20 * time.Minute)
+	remaining, _ = s.Remaining(key)
+	require.Equal(t, 40*time.Minute, remaining)
+
+	// Rescheduling replaces the previous deadline
+	require.True(t, s.Schedule(key, now.Add(30*time.Minute)))
+	remaining, _ = s.Remaining(key)
+	require.Equal(t, 10*time.Minute, remaining)
+	require.Equal(t, []ctrl.Request{{NamespacedName: key}}, s.Requests())
+
+	s.Remove(key)
+	_, found = s.Remaining(key)
+	require.False(t, found)
+	require.Empty(t, s.Requests())
+}
+
+func Test_ExpirySchedulerPopsInDeadlineOrder(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(now)
+	s := NewExpiryScheduler(clk)
+
+	a := types.NamespacedName{Namespace: "default", Name: "a"}
+	b := types.NamespacedName{Namespace: "default", Name: "b"}
+	c := types.NamespacedName{Namespace: "default", Name: "c"}
+	d := types.NamespacedName{Namespace: "default", Name: "d"}
+
+	s.Schedule(c, now.Add(3*time.Minute))
+	s.Schedule(a, now.Add(1*time.Minute))
+	s.Schedule(d, now.Add(4*time.Minute))
+	s.Schedule(b, now.Add(2*time.Minute))
+	s.Remove(d)
+
+	require.Empty(t, s.popExpired())
+
+	wait, ok := s.nextWait()
+	require.True(t, ok)
+	require.Equal(t, time.Minute, wait)
+
+	clk.Step(2 * time.Minute)
+	require.Equal(t, []types.NamespacedName{a, b}, s.popExpired())
+
+	clk.Step(time.Hour)
+	require.Equal(t, []types.NamespacedName{c}, s.popExpired())
+
+	_, ok = s.nextWait()
+	require.False(t, ok)
+}
+
+func Test_ExpirySchedulerEmitsEvents(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(now)
+	s := NewExpiryScheduler(clk)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error)
+	go func() { done <- s.Start(ctx) }()
+
+	early := types.NamespacedName{Namespace: "default", Name: "early"}
+	late := types.NamespacedName{Namespace: "default", Name: "late"}
+	s.Schedule(late, now.Add(time.Hour))
+	s.Schedule(early, now.Add(time.Minute))
+
+	receive := func() event.GenericEvent {
+		select {
+		case e := <-s.Events():
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for expiry event")
+			return event.GenericEvent{}
+		}
+	}
+
+	// Wait until the scheduler waits for the earliest deadline
+	require.Eventually(t, clk.HasWaiters, 5*time.Second, time.Millisecond)
+	clk.Step(time.Minute)
+	e := receive()
+	require.Equal(t, early.Name, e.Object.GetName())
+	require.Equal(t, early.Namespace, e.Object.GetNamespace())
+
+	// Moving a deadline into the past emits the event immediately
+	s.Schedule(late, now)
+	e = receive()
+	require.Equal(t, late.Name, e.Object.GetName())
+
+	cancel()
+	require.NoError(t, <-done)
+}
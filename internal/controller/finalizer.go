@@ -0,0 +1,129 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// reconcileTerminating handles pods which have already been deleted but are kept by
+// their finalizers. Deletes are not repeated. Once a pod has been terminating for
+// FinalizerTimeout, it is counted as stuck and the finalizers listed in StaleFinalizers
+// are removed if podbouncer would have deleted the pod itself.
+func (r *PodReconciler) reconcileTerminating(ctx context.Context, pod *v1.Pod) (ctrl.Result, error) {
+	key := client.ObjectKeyFromObject(pod)
+
+	if len(pod.Finalizers) == 0 || r.FinalizerTimeout <= 0 {
+		r.stuck.remove(key)
+		return ctrl.Result{}, nil
+	}
+
+	terminatingFor := r.Clock.Since(pod.DeletionTimestamp.Time)
+	if terminatingFor < r.FinalizerTimeout {
+		r.stuck.remove(key)
+		return ctrl.Result{RequeueAfter: r.FinalizerTimeout - terminatingFor}, nil
+	}
+
+	var stale, remaining []string
+	for _, f := range pod.Finalizers {
+		if slices.Contains(r.StaleFinalizers, f) {
+			stale = append(stale, f)
+		} else {
+			remaining = append(remaining, f)
+		}
+	}
+
+	if len(stale) == 0 || !r.expiredWhenDeleted(pod) {
+		r.stuck.set(key, pod.Finalizers)
+		return ctrl.Result{}, nil
+	}
+
+	if r.Config.Paused() {
+		r.stuck.set(key, pod.Finalizers)
+		return ctrl.Result{RequeueAfter: pausedRecheckInterval}, nil
+	}
+
+	patch := client.MergeFromWithOptions(pod.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	pod.Finalizers = remaining
+	if err := r.Patch(ctx, pod, patch); err != nil {
+		r.stuck.set(key, pod.Finalizers)
+		return ctrl.Result{}, client.IgnoreNotFound(fmt.Errorf("failed to remove stale finalizers: %w", err))
+	}
+
+	for _, f := range stale {
+		finalizersRemovedTotal.WithLabelValues(f).Inc()
+	}
+	r.stuck.set(key, remaining)
+
+	log.FromContext(ctx).Info("Removed stale finalizers", "finalizers", stale, "terminatingFor", terminatingFor)
+
+	return ctrl.Result{}, nil
+}
+
+// expiredWhenDeleted reports whether the terminating pod was an expired candidate at the
+// time it was deleted. Finalizers of pods deleted for other reasons, e.g. by their owner
+// or a user, are left to whoever deleted them.
+func (r *PodReconciler) expiredWhenDeleted(pod *v1.Pod) bool {
+	deleted := pod.DeepCopy()
+	deleted.DeletionTimestamp = nil
+
+	decision, err := EvaluatePod(deleted, r.Config, pod.DeletionTimestamp.Time)
+	return err == nil && decision.Candidate && decision.Expired
+}
+
+// stuckFinalizerTracker counts the finalizers of pods which have been terminating
+// for longer than the finalizer timeout. The zero value is ready to use.
+type stuckFinalizerTracker struct {
+	mu   sync.Mutex
+	pods map[types.NamespacedName][]string
+}
+
+// set replaces the stuck finalizers of the given pod.
+func (t *stuckFinalizerTracker) set(key types.NamespacedName, finalizers []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, f := range t.pods[key] {
+		stuckFinalizers.WithLabelValues(f).Dec()
+	}
+
+	if len(finalizers) == 0 {
+		delete(t.pods, key)
+		return
+	}
+
+	if t.pods == nil {
+		t.pods = make(map[types.NamespacedName][]string)
+	}
+	t.pods[key] = slices.Clone(finalizers)
+	for _, f := range finalizers {
+		stuckFinalizers.WithLabelValues(f).Inc()
+	}
+}
+
+func (t *stuckFinalizerTracker) remove(key types.NamespacedName) {
+	t.set(key, nil)
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_PodReconcilerTerminatingPods(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(now)
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "default",
+			Name:              "some-pod",
+			CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour)),
+			DeletionTimestamp: &metav1.Time{Time: now.Add(-time.Minute)},
+			Finalizers:        []string{"example.com/stale", "example.com/other"},
+		},
+		Status: v1.PodStatus{Phase: v1.PodFailed},
+	}
+	key := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
+	req := ctrl.Request{NamespacedName: key}
+
+	r := &PodReconciler{
+		Client:           fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(pod).Build(),
+		Scheme:           scheme.Scheme,
+		Config:           NewPodReconcilerConfig(),
+		Clock:            clk,
+		Expiry:           NewExpiryScheduler(clk),
+		FinalizerTimeout: 10 * time.Minute,
+		StaleFinalizers:  []string{"example.com/stale"},
+	}
+
+	get := func() *v1.Pod {
+		var current v1.Pod
+		require.NoError(t, r.Get(context.Background(), key, &current))
+		return &current
+	}
+
+	// Terminating pods are not deleted again and are re-evaluated once the timeout has passed
+	result, err := r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, ctrl.Result{RequeueAfter: 9 * time.Minute}, result)
+	require.Len(t, get().Finalizers, 2)
+
+	// Only the allowed finalizers are removed from stuck pods
+	clk.Step(9 * time.Minute)
+	result, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, ctrl.Result{}, result)
+	require.Equal(t, []string{"example.com/other"}, get().Finalizers)
+	require.Equal(t, 1.0, testutil.ToFloat64(stuckFinalizers.WithLabelValues("example.com/other")))
+	require.Equal(t, 0.0, testutil.ToFloat64(stuckFinalizers.WithLabelValues("example.com/stale")))
+
+	// Pods which are gone are no longer counted
+	pod = get()
+	pod.Finalizers = nil
+	require.NoError(t, r.Update(context.Background(), pod))
+
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, 0.0, testutil.ToFloat64(stuckFinalizers.WithLabelValues("example.com/other")))
+
+}
+
+func Test_PodReconcilerTerminatingPodsDeletedByOthers(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(now)
+
+	// Pods which were deleted before they expired or in a phase podbouncer ignores
+	young := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "default",
+			Name:              "young-pod",
+			CreationTimestamp: metav1.NewTime(now.Add(-30 * time.Minute)),
+			DeletionTimestamp: &metav1.Time{Time: now.Add(-20 * time.Minute)},
+			Finalizers:        []string{"example.com/orphaned"},
+		},
+		Status: v1.PodStatus{Phase: v1.PodFailed},
+	}
+	running := young.DeepCopy()
+	running.Name = "running-pod"
+	running.CreationTimestamp = metav1.NewTime(now.Add(-2 * time.Hour))
+	running.Status.Phase = v1.PodRunning
+
+	r := &PodReconciler{
+		Client:           fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(young, running).Build(),
+		Scheme:           scheme.Scheme,
+		Config:           NewPodReconcilerConfig(),
+		Clock:            clk,
+		Expiry:           NewExpiryScheduler(clk),
+		FinalizerTimeout: 10 * time.Minute,
+		StaleFinalizers:  []string{"example.com/orphaned"},
+	}
+
+	for _, pod := range []*v1.Pod{young, running} {
+		key := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
+		_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		require.NoError(t, err)
+
+		var current v1.Pod
+		require.NoError(t, r.Get(context.Background(), key, &current))
+		require.Equal(t, []string{"example.com/orphaned"}, current.Finalizers, pod.Name)
+	}
+	require.Equal(t, 2.0, testutil.ToFloat64(stuckFinalizers.WithLabelValues("example.com/orphaned")))
+}
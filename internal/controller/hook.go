@@ -0,0 +1,43 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// PreDeleteHook runs before PodReconciler or PodSweeper delete a pod. Hooks are not
+// run in dry run mode.
+//
+// If a hook returns an error, the pod is not deleted. PodReconciler retries the
+// reconciliation with backoff while PodSweeper retries with the next sweep.
+type PreDeleteHook interface {
+	BeforeDelete(ctx context.Context, pod *v1.Pod, decision PodDecision) error
+}
+
+// runPreDeleteHooks runs all hooks in order and stops at the first error.
+func runPreDeleteHooks(ctx context.Context, hooks []PreDeleteHook, pod *v1.Pod, decision PodDecision) error {
+	for _, h := range hooks {
+		if err := h.BeforeDelete(ctx, pod, decision); err != nil {
+			return fmt.Errorf("pre-delete hook failed: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type hookFunc func(ctx context.Context, pod *v1.Pod, decision PodDecision) error
+
+func (f hookFunc) BeforeDelete(ctx context.Context, pod *v1.Pod, decision PodDecision) error {
+	return f(ctx, pod, decision)
+}
+
+func Test_PodReconcilerPreDeleteHooks(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(now)
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "default",
+			Name:              "some-pod",
+			CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour)),
+		},
+		Status: v1.PodStatus{Phase: v1.PodFailed},
+	}
+	key := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
+
+	hookErr := errors.New("archive unavailable")
+	var calls int
+	r := &PodReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(pod).Build(),
+		Scheme: scheme.Scheme,
+		Config: NewPodReconcilerConfig(),
+		Clock:  clk,
+		Expiry: NewExpiryScheduler(clk),
+		PreDeleteHooks: []PreDeleteHook{hookFunc(func(_ context.Context, p *v1.Pod, d PodDecision) error {
+			calls++
+			require.Equal(t, pod.Name, p.Name)
+			require.True(t, d.Expired)
+			if calls == 1 {
+				return hookErr
+			}
+			return nil
+		})},
+	}
+
+	// A failing hook prevents the deletion
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	require.ErrorIs(t, err, hookErr)
+	require.NoError(t, r.Get(context.Background(), key, &v1.Pod{}))
+
+	// The pod is deleted once the hook succeeds
+	_, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.True(t, apierrors.IsNotFound(r.Get(context.Background(), key, &v1.Pod{})))
+	require.Equal(t, 2, calls)
+}
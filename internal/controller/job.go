@@ -0,0 +1,152 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;delete
+
+// deleteExpiredPod runs the pre-delete hooks for pod and deletes it. If deleteJobs is set
+// and pod is controlled by a finished Job which is not cleaned up by its own TTL, the Job
+// is deleted instead and its pods are removed by the garbage collector. This only happens
+// if every pod of the Job is an expired candidate, the hooks then run for all of them.
+//
+// It returns the deleted object and the pods deleted with it. Observers must be notified
+// about each of these pods.
+func deleteExpiredPod(ctx context.Context, c client.Client, hooks []PreDeleteHook, expired expiredPod,
+	config *PodReconcilerConfig, now time.Time, deleteJobs bool) (client.Object, []expiredPod, error) {
+	pods := []expiredPod{expired}
+
+	var job *batchv1.Job
+	if deleteJobs {
+		var err error
+		if job, err = finishedJob(ctx, c, expired.pod); err != nil {
+			return nil, nil, err
+		}
+		if job != nil {
+			jobPods, err := expiredJobPods(ctx, c, job, expired.pod, config, now)
+			if err != nil {
+				return nil, nil, err
+			}
+			if jobPods != nil {
+				pods = jobPods
+			} else {
+				job = nil
+			}
+		}
+	}
+
+	for _, p := range pods {
+		if err := runPreDeleteHooks(ctx, hooks, p.pod, p.decision); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if job != nil {
+		return job, pods, deleteJob(ctx, c, job)
+	}
+	return expired.pod, pods, deletePod(ctx, c, expired.pod)
+}
+
+// finishedJob returns the Job controlling pod if it has finished, has no active pods
+// and has no ttlSecondsAfterFinished. It returns nil for all other pods.
+func finishedJob(ctx context.Context, c client.Reader, pod *v1.Pod) (*batchv1.Job, error) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil || owner.Kind != "Job" || owner.APIVersion != batchv1.SchemeGroupVersion.String() {
+		return nil, nil
+	}
+
+	var job batchv1.Job
+	if err := c.Get(ctx, client.ObjectKey{Namespace: pod.Namespace, Name: owner.Name}, &job); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+
+	if job.UID != owner.UID || job.Spec.TTLSecondsAfterFinished != nil || job.Status.Active > 0 || jobCondition(&job) == "" {
+		return nil, nil
+	}
+
+	return &job, nil
+}
+
+// expiredJobPods returns the pods controlled by job together with their decisions. Pods
+// which are already terminating are skipped. It returns nil if any other pod is not an
+// expired candidate, e.g. since it is kept or has not reached its max age yet, or if the
+// pods cannot be listed by the controller UID of the Job. The Job must not be deleted then.
+func expiredJobPods(ctx context.Context, c client.Reader, job *batchv1.Job, pod *v1.Pod,
+	config *PodReconcilerConfig, now time.Time) ([]expiredPod, error) {
+	var list v1.PodList
+	if err := c.List(ctx, &list, client.InNamespace(job.Namespace),
+		client.MatchingLabels{batchv1.ControllerUidLabel: string(job.UID)}); err != nil {
+		return nil, fmt.Errorf("failed to list pods of job: %w", err)
+	}
+
+	var pods []expiredPod
+	found := false
+	for i := range list.Items {
+		p := &list.Items[i]
+		if owner := metav1.GetControllerOf(p); owner == nil || owner.UID != job.UID || p.DeletionTimestamp != nil {
+			continue
+		}
+
+		decision, err := EvaluatePod(p, config, now)
+		if err != nil || !decision.Candidate || !decision.Expired {
+			return nil, nil
+		}
+
+		found = found || p.UID == pod.UID
+		pods = append(pods, expiredPod{pod: p, decision: decision})
+	}
+
+	if !found {
+		return nil, nil
+	}
+	return pods, nil
+}
+
+// jobCondition returns the type of the condition marking job as finished, or an
+// empty string if it is still active.
+func jobCondition(job *batchv1.Job) batchv1.JobConditionType {
+	for _, c := range job.Status.Conditions {
+		if (c.Type == batchv1.JobComplete || c.Type == batchv1.JobFailed) && c.Status == v1.ConditionTrue {
+			return c.Type
+		}
+	}
+	return ""
+}
+
+// deleteJob deletes job with background propagation and records the deletion metrics.
+func deleteJob(ctx context.Context, c client.Client, job *batchv1.Job) error {
+	if err := c.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+		return err
+	}
+
+	jobsDeletedTotal.WithLabelValues(string(jobCondition(job))).Inc()
+	return nil
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_PodReconcilerDeletesFinishedJobs(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	finished := batchv1.JobStatus{Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: v1.ConditionTrue}}}
+
+	type Test struct {
+		Name             string
+		Job              *batchv1.Job
+		ExpectJobDeleted bool
+		ExpectPodDeleted bool
+	}
+
+	tests := []Test{
+		{
+			Name:             "finished job",
+			Job:              &batchv1.Job{Status: finished},
+			ExpectJobDeleted: true,
+		},
+		{
+			Name: "failed job",
+			Job: &batchv1.Job{Status: batchv1.JobStatus{Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobFailed, Status: v1.ConditionTrue},
+			}}},
+			ExpectJobDeleted: true,
+		},
+		{
+			Name:             "finished job with ttl",
+			Job:              &batchv1.Job{Spec: batchv1.JobSpec{TTLSecondsAfterFinished: ptr.To[int32](60)}, Status: finished},
+			ExpectPodDeleted: true,
+		},
+		{
+			Name: "finished job with active pods",
+			Job: &batchv1.Job{Status: batchv1.JobStatus{Active: 1, Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobFailed, Status: v1.ConditionTrue},
+			}}},
+			ExpectPodDeleted: true,
+		},
+		{
+			Name:             "active job",
+			Job:              &batchv1.Job{},
+			ExpectPodDeleted: true,
+		},
+		{
+			Name:             "missing job",
+			ExpectPodDeleted: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			clk := clocktesting.NewFakeClock(now)
+
+			pod := &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:         "default",
+					Name:              "some-job-abcde",
+					UID:               "pod-uid",
+					CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour)),
+					Labels:            map[string]string{batchv1.ControllerUidLabel: "job-uid"},
+					OwnerReferences: []metav1.OwnerReference{{
+						APIVersion: "batch/v1",
+						Kind:       "Job",
+						Name:       "some-job",
+						UID:        "job-uid",
+						Controller: ptr.To(true),
+					}},
+				},
+				Status: v1.PodStatus{Phase: v1.PodSucceeded},
+			}
+
+			objects := []client.Object{pod}
+			if test.Job != nil {
+				test.Job.ObjectMeta = metav1.ObjectMeta{Namespace: "default", Name: "some-job", UID: "job-uid"}
+				objects = append(objects, test.Job)
+			}
+
+			r := &PodReconciler{
+				Client:             fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build(),
+				Scheme:             scheme.Scheme,
+				Config:             NewPodReconcilerConfig(),
+				Clock:              clk,
+				Expiry:             NewExpiryScheduler(clk),
+				DeleteFinishedJobs: true,
+			}
+
+			_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pod)})
+			require.NoError(t, err)
+
+			err = r.Get(context.Background(), client.ObjectKeyFromObject(pod), &v1.Pod{})
+			require.Equal(t, test.ExpectPodDeleted, apierrors.IsNotFound(err), "unexpected pod deletion")
+
+			if test.Job != nil {
+				err = r.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "some-job"}, &batchv1.Job{})
+				require.Equal(t, test.ExpectJobDeleted, apierrors.IsNotFound(err), "unexpected job deletion")
+			}
+		})
+	}
+}
+
+type recordingDeletionObserver struct {
+	mu      sync.Mutex
+	deleted []string
+}
+
+func (o *recordingDeletionObserver) ObserveDeletion(_ context.Context, pod *v1.Pod, _ PodDecision, _ bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.deleted = append(o.deleted, pod.Name)
+}
+
+func Test_PodReconcilerDeletesFinishedJobsWithAllPods(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	newPod := func(name string, age time.Duration) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "default",
+				Name:              name,
+				UID:               types.UID(name + "-uid"),
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+				Labels:            map[string]string{batchv1.ControllerUidLabel: "job-uid"},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "batch/v1",
+					Kind:       "Job",
+					Name:       "some-job",
+					UID:        "job-uid",
+					Controller: ptr.To(true),
+				}},
+			},
+			Status: v1.PodStatus{Phase: v1.PodFailed},
+		}
+	}
+
+	kept := newPod("some-job-kept", 3*time.Hour)
+	kept.Annotations = map[string]string{KeepAnnotation: "true"}
+
+	type Test struct {
+		Name             string
+		Sibling          *v1.Pod
+		ExpectJobDeleted bool
+		ExpectDeleted    []string
+	}
+
+	tests := []Test{
+		{
+			Name:             "all pods expired",
+			Sibling:          newPod("some-job-retry", 90*time.Minute),
+			ExpectJobDeleted: true,
+			ExpectDeleted:    []string{"some-job-first", "some-job-retry"},
+		},
+		{
+			Name:          "younger pod",
+			Sibling:       newPod("some-job-retry", 30*time.Minute),
+			ExpectDeleted: []string{"some-job-first"},
+		},
+		{
+			Name:          "kept pod",
+			Sibling:       kept,
+			ExpectDeleted: []string{"some-job-first"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			clk := clocktesting.NewFakeClock(now)
+
+			pod := newPod("some-job-first", 2*time.Hour)
+			job := &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "some-job", UID: "job-uid"},
+				Status:     batchv1.JobStatus{Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: v1.ConditionTrue}}},
+			}
+
+			var hooked []string
+			observer := &recordingDeletionObserver{}
+			r := &PodReconciler{
+				Client:             fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(pod, test.Sibling, job).Build(),
+				Scheme:             scheme.Scheme,
+				Config:             NewPodReconcilerConfig(),
+				Clock:              clk,
+				Expiry:             NewExpiryScheduler(clk),
+				DeleteFinishedJobs: true,
+				PreDeleteHooks: []PreDeleteHook{hookFunc(func(_ context.Context, pod *v1.Pod, _ PodDecision) error {
+					hooked = append(hooked, pod.Name)
+					return nil
+				})},
+				Observers: []DeletionObserver{observer},
+			}
+
+			_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pod)})
+			require.NoError(t, err)
+
+			err = r.Get(context.Background(), client.ObjectKeyFromObject(job), &batchv1.Job{})
+			require.Equal(t, test.ExpectJobDeleted, apierrors.IsNotFound(err), "unexpected job deletion")
+
+			err = r.Get(context.Background(), client.ObjectKeyFromObject(test.Sibling), &v1.Pod{})
+			require.NoError(t, err, "sibling must only be deleted by the garbage collector")
+
+			require.ElementsMatch(t, test.ExpectDeleted, hooked)
+			require.ElementsMatch(t, test.ExpectDeleted, observer.deleted)
+		})
+	}
+}
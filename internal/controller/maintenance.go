@@ -0,0 +1,165 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// MaintenanceWindows are recurring periods during which pods may be deleted.
+//
+// A window is written as a cron expression followed by its duration, e.g.
+// "0 9 * * mon-fri 8h" for business hours. Multiple windows are separated by
+// semicolons or new lines. A nil *MaintenanceWindows allows deletions at any time.
+type MaintenanceWindows struct {
+	spec     string
+	location *time.Location
+	windows  []maintenanceWindow
+}
+
+type maintenanceWindow struct {
+	schedule *cronSchedule
+	duration time.Duration
+}
+
+// ParseMaintenanceWindows parses the windows in spec, whose cron expressions are
+// evaluated in the IANA time zone timeZone. An empty timeZone means UTC.
+func ParseMaintenanceWindows(spec, timeZone string) (*MaintenanceWindows, error) {
+	location := time.UTC
+	if timeZone != "" {
+		var err error
+		if location, err = time.LoadLocation(timeZone); err != nil {
+			return nil, fmt.Errorf("invalid time zone %q: %w", timeZone, err)
+		}
+	}
+
+	w := &MaintenanceWindows{spec: spec, location: location}
+
+	lines := strings.FieldsFunc(spec, func(r rune) bool { return r == ';' || r == '\n' })
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("maintenance window %q must consist of a cron expression and a duration", strings.TrimSpace(line))
+		}
+
+		schedule, err := parseCronSchedule(strings.Join(fields[:5], " "))
+		if err != nil {
+			return nil, err
+		}
+		if schedule.next(time.Date(2000, 1, 1, 0, 0, 0, 0, location)).IsZero() {
+			return nil, fmt.Errorf("maintenance window %q never opens", strings.TrimSpace(line))
+		}
+
+		duration, err := time.ParseDuration(fields[5])
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid duration %q of maintenance window, must be positive", fields[5])
+		}
+
+		w.windows = append(w.windows, maintenanceWindow{schedule: schedule, duration: duration})
+	}
+
+	if len(w.windows) == 0 {
+		return nil, errors.New("no maintenance window given")
+	}
+
+	return w, nil
+}
+
+// Open reports whether t is within one of the windows.
+func (w *MaintenanceWindows) Open(t time.Time) bool {
+	if w == nil {
+		return true
+	}
+
+	t = t.In(w.location)
+	for _, window := range w.windows {
+		// The latest opening before t is the first one after t - duration
+		opening := window.schedule.next(t.Add(-window.duration))
+		if !opening.IsZero() && !opening.After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpening returns the earliest time at or after t at which a window is open.
+func (w *MaintenanceWindows) NextOpening(t time.Time) time.Time {
+	if w.Open(t) {
+		return t
+	}
+
+	var earliest time.Time
+	for _, window := range w.windows {
+		opening := window.schedule.next(t.In(w.location))
+		if !opening.IsZero() && (earliest.IsZero() || opening.Before(earliest)) {
+			earliest = opening
+		}
+	}
+	return earliest
+}
+
+// String returns the windows as they were parsed.
+func (w *MaintenanceWindows) String() string {
+	if w == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s (%s)", w.spec, w.location)
+}
+
+// DeletionTime returns when a candidate is deleted under config: Once it has expired
+// and a maintenance window is open. The returned time is not before now.
+func DeletionTime(decision PodDecision, config *PodReconcilerConfig, now time.Time) time.Time {
+	t := decision.ExpiresAt
+	if t.Before(now) {
+		t = now
+	}
+	return config.MaintenanceWindows().NextOpening(t)
+}
+
+// deferDeletion schedules an expired pod for the next opening of windows instead of
+// deleting it now.
+func (r *PodReconciler) deferDeletion(ctx context.Context, pod *v1.Pod, decision PodDecision, windows *MaintenanceWindows) (ctrl.Result, error) {
+	key := client.ObjectKeyFromObject(pod)
+
+	opening := windows.NextOpening(r.Clock.Now())
+	if opening.IsZero() {
+		r.forgetPod(key)
+		return ctrl.Result{}, nil
+	}
+
+	// Count deferrals, not reconciles of pods which are already deferred
+	decision.ExpiresAt = opening
+	if r.Expiry.Schedule(key, opening) {
+		log.FromContext(ctx).V(1).Info("Deferring deletion to the next maintenance window", "opening", opening)
+		deletionsDeferredTotal.Inc()
+		observeSchedule(ctx, r.ScheduleObservers, pod, decision)
+	}
+	r.warnPendingDeletion(pod, decision)
+	return ctrl.Result{}, r.annotateDeleteAfter(ctx, pod, decision)
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_MaintenanceWindows(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	w, err := ParseMaintenanceWindows("0 9 * * mon-fri 8h;\n0 22 * * sat 30m", "Europe/Berlin")
+	require.NoError(t, err)
+
+	// 2024-01-01 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, berlin)
+	}
+
+	require.False(t, w.Open(at(1, 8, 59)))
+	require.True(t, w.Open(at(1, 9, 0)))
+	require.True(t, w.Open(at(1, 16, 59)))
+	require.False(t, w.Open(at(1, 17, 0)))
+	require.True(t, w.Open(at(6, 22, 15)))
+	require.False(t, w.Open(at(6, 22, 30)))
+
+	require.Equal(t, at(1, 12, 0), w.NextOpening(at(1, 12, 0)))
+	require.Equal(t, at(2, 9, 0), w.NextOpening(at(1, 17, 0)))
+	require.Equal(t, at(6, 22, 0), w.NextOpening(at(5, 17, 0)))
+	require.Equal(t, at(8, 9, 0), w.NextOpening(at(6, 23, 0)))
+
+	// Windows are evaluated in their time zone
+	require.True(t, w.Open(time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)))
+
+	var always *MaintenanceWindows
+	require.True(t, always.Open(at(1, 3, 0)))
+	require.Equal(t, at(1, 3, 0), always.NextOpening(at(1, 3, 0)))
+}
+
+func Test_ParseMaintenanceWindowsRejectsInvalidWindows(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		";",
+		"0 9 * * *",
+		"0 9 * * * 0s",
+		"0 9 * * * -1h",
+		"0 25 * * * 1h",
+		"0 0 30 2 * 1h",
+	} {
+		_, err := ParseMaintenanceWindows(spec, "")
+		require.Error(t, err, spec)
+	}
+}
+
+func Test_DeletionTime(t *testing.T) {
+	now := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	config := NewPodReconcilerConfig()
+
+	require.Equal(t, now, DeletionTime(PodDecision{ExpiresAt: now.Add(-time.Hour)}, config, now))
+	require.Equal(t, now.Add(time.Hour), DeletionTime(PodDecision{ExpiresAt: now.Add(time.Hour)}, config, now))
+
+	windows, err := ParseMaintenanceWindows("0 9 * * * 8h", "")
+	require.NoError(t, err)
+	config.SetMaintenanceWindows(windows)
+
+	opening := time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)
+	require.Equal(t, opening, DeletionTime(PodDecision{ExpiresAt: now.Add(-time.Hour)}, config, now))
+	require.Equal(t, opening.Add(time.Hour), DeletionTime(PodDecision{ExpiresAt: opening.Add(time.Hour)}, config, now))
+}
+
+func Test_PodReconcilerDefersDeletionToMaintenanceWindow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(now)
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "default",
+			Name:              "some-pod",
+			CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour)),
+		},
+		Status: v1.PodStatus{Phase: v1.PodFailed},
+	}
+	key := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
+	req := ctrl.Request{NamespacedName: key}
+
+	windows, err := ParseMaintenanceWindows("0 9 * * * 8h", "")
+	require.NoError(t, err)
+	config := NewPodReconcilerConfig()
+	config.SetMaintenanceWindows(windows)
+
+	r := &PodReconciler{
+		Client:              fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(pod).Build(),
+		Scheme:              scheme.Scheme,
+		Config:              config,
+		Clock:               clk,
+		Expiry:              NewExpiryScheduler(clk),
+		AnnotateDeleteAfter: true,
+	}
+
+	// Outside of the window, the pod waits for the next opening. Further reconciles of the
+	// deferred pod are not counted again.
+	deferred := testutil.ToFloat64(deletionsDeferredTotal)
+	for i := 0; i < 2; i++ {
+		_, err = r.Reconcile(context.Background(), req)
+		require.NoError(t, err)
+	}
+	require.Equal(t, deferred+1, testutil.ToFloat64(deletionsDeferredTotal))
+
+	var current v1.Pod
+	require.NoError(t, r.Get(context.Background(), key, &current))
+	require.Equal(t, "2024-01-02T09:00:00Z", current.Annotations[DeleteAfterAnnotation])
+	remaining, found := r.Expiry.Remaining(key)
+	require.True(t, found)
+	require.Equal(t, 13*time.Hour, remaining)
+
+	clk.Step(13 * time.Hour)
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.True(t, apierrors.IsNotFound(r.Get(context.Background(), key, &current)))
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Metrics complementing the workqueue and reconcile metrics of controller-runtime
+// (e.g. workqueue_depth, controller_runtime_reconcile_time_seconds).
+var (
+	podDecisionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "podbouncer_pod_decisions_total",
+		Help: "Number of pod evaluations by their outcome.",
+	}, []string{"decision"})
+
+	podsDeletedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "podbouncer_pods_deleted_total",
+		Help: "Number of deleted pods by their phase.",
+	}, []string{"phase"})
+
+	jobsDeletedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "podbouncer_jobs_deleted_total",
+		Help: "Number of finished Jobs deleted instead of their pods by their condition.",
+	}, []string{"condition"})
+
+	recreatablePodsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "podbouncer_recreatable_pods_total",
+		Help: "Number of expired pods whose owner would recreate them by the action taken.",
+	}, []string{"action"})
+
+	churningOwners = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "podbouncer_churning_owners",
+		Help: "Number of owners whose pods have been deleted at least the churn threshold times within the churn window.",
+	})
+
+	ownerChurnDetectedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "podbouncer_owner_churn_detected_total",
+		Help: "Number of times an owner crossed the churn threshold by the kind of the owner.",
+	}, []string{"kind"})
+
+	deletionsDeferredTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "podbouncer_deletions_deferred_total",
+		Help: "Number of times the deletion of an expired pod was deferred to the next maintenance window.",
+	})
+
+	pausedGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "podbouncer_paused",
+		Help: "Whether destructive actions are paused (1) or not (0).",
+	})
+
+	configLastUpdate = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "podbouncer_config_last_update_timestamp_seconds",
+		Help: "Unix time of the last update of the configuration.",
+	})
+
+	podDeletionDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "podbouncer_pod_deletion_duration_seconds",
+		Help:    "Duration of pod delete requests.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	scheduledPods = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "podbouncer_scheduled_pods",
+		Help: "Number of pods waiting to reach their max age.",
+	})
+
+	pendingWarnings = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "podbouncer_pending_deletion_warnings",
+		Help: "Number of pods waiting to receive a warning about their deletion.",
+	})
+
+	stuckFinalizers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "podbouncer_stuck_finalizers",
+		Help: "Number of pods terminating for longer than the finalizer timeout by their finalizer.",
+	}, []string{"finalizer"})
+
+	finalizersRemovedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "podbouncer_finalizers_removed_total",
+		Help: "Number of stale finalizers removed from terminating pods.",
+	}, []string{"finalizer"})
+
+	sweepDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "podbouncer_sweep_duration_seconds",
+		Help:    "Duration of sweeps in sweep mode.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 15),
+	})
+
+	maxConcurrentReconciles = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "podbouncer_max_concurrent_reconciles",
+		Help: "Configured number of concurrent workers per controller.",
+	}, []string{"controller"})
+)
+
+// Values of the decision label of podDecisionsTotal.
+const (
+	decisionIgnored = "ignored"
+	decisionWaiting = "waiting"
+	decisionExpired = "expired"
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		podDecisionsTotal,
+		podsDeletedTotal,
+		jobsDeletedTotal,
+		recreatablePodsTotal,
+		churningOwners,
+		ownerChurnDetectedTotal,
+		deletionsDeferredTotal,
+		pausedGauge,
+		configLastUpdate,
+		podDeletionDuration,
+		scheduledPods,
+		pendingWarnings,
+		stuckFinalizers,
+		finalizersRemovedTotal,
+		sweepDuration,
+		maxConcurrentReconciles,
+	)
+}
+
+// recordDecision counts the outcome of a pod evaluation.
+func recordDecision(d PodDecision) {
+	switch {
+	case !d.Candidate:
+		podDecisionsTotal.WithLabelValues(decisionIgnored).Inc()
+	case d.Expired:
+		podDecisionsTotal.WithLabelValues(decisionExpired).Inc()
+	default:
+		podDecisionsTotal.WithLabelValues(decisionWaiting).Inc()
+	}
+}
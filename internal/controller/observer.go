@@ -0,0 +1,113 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// PodRecord describes a pod and the decision about it. It is the common part of the
+// records emitted by observers.
+type PodRecord struct {
+	UID       types.UID   `json:"uid"`
+	Namespace string      `json:"namespace"`
+	Name      string      `json:"name"`
+	Owner     string      `json:"owner,omitempty"`
+	Phase     v1.PodPhase `json:"phase"`
+	Reason    string      `json:"reason,omitempty"`
+	Age       string      `json:"age"`
+	MaxPodAge string      `json:"maxPodAge"`
+	Rule      string      `json:"rule"`
+}
+
+// NewPodRecord creates the PodRecord of pod. The owner is the "Kind/Name" of the
+// controller of pod, if any.
+func NewPodRecord(pod *v1.Pod, decision PodDecision) PodRecord {
+	record := PodRecord{
+		UID:       pod.UID,
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		Phase:     pod.Status.Phase,
+		Reason:    pod.Status.Reason,
+		Age:       decision.Age.Round(time.Second).String(),
+		MaxPodAge: decision.MaxPodAge.String(),
+		Rule:      decision.Rule,
+	}
+	if owner := metav1.GetControllerOf(pod); owner != nil {
+		record.Owner = owner.Kind + "/" + owner.Name
+	}
+	return record
+}
+
+// DeletionObserver is notified about every pod deletion carried out by PodReconciler
+// or PodSweeper. In dry run mode, observers are notified about the skipped deletions.
+//
+// Implementations must be safe for concurrent use and must not block for long
+// since they are called synchronously.
+type DeletionObserver interface {
+	ObserveDeletion(ctx context.Context, pod *v1.Pod, decision PodDecision, dryRun bool)
+}
+
+// observeDeletion notifies all observers about the deletion of pod.
+func observeDeletion(ctx context.Context, observers []DeletionObserver, pod *v1.Pod, decision PodDecision, dryRun bool) {
+	for _, o := range observers {
+		o.ObserveDeletion(ctx, pod, decision, dryRun)
+	}
+}
+
+// ScheduleObserver is notified when PodReconciler schedules a pod for deletion and
+// whenever the deletion deadline of a scheduled pod changes.
+//
+// Implementations must be safe for concurrent use and must not block for long
+// since they are called synchronously.
+type ScheduleObserver interface {
+	ObserveSchedule(ctx context.Context, pod *v1.Pod, decision PodDecision)
+}
+
+// observeSchedule notifies all observers about the scheduled deletion of pod.
+func observeSchedule(ctx context.Context, observers []ScheduleObserver, pod *v1.Pod, decision PodDecision) {
+	for _, o := range observers {
+		o.ObserveSchedule(ctx, pod, decision)
+	}
+}
+
+// ConfigUpdate describes an applied configuration change.
+type ConfigUpdate struct {
+	// Source names the configuration source, e.g. the ConfigMap or file.
+	Source string
+
+	MaxPodAge         time.Duration
+	PreviousMaxPodAge time.Duration
+}
+
+// ConfigObserver is notified every time ConfigMapReconciler or FileConfigSource
+// update the configuration.
+type ConfigObserver interface {
+	ObserveConfigUpdate(ctx context.Context, update ConfigUpdate)
+}
+
+// observeConfigUpdate notifies all observers about update.
+func observeConfigUpdate(ctx context.Context, observers []ConfigObserver, update ConfigUpdate) {
+	for _, o := range observers {
+		o.ObserveConfigUpdate(ctx, update)
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	runtimecontroller "sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ReconcilerOptions configure the throughput of a controller.
+//
+// The zero value uses the defaults of controller-runtime.
+type ReconcilerOptions struct {
+	// MaxConcurrentReconciles is the number of workers reconciling requests concurrently.
+	MaxConcurrentReconciles int
+
+	// RateLimitBaseDelay and RateLimitMaxDelay configure the per request exponential
+	// backoff after failed reconciliations.
+	RateLimitBaseDelay time.Duration
+	RateLimitMaxDelay  time.Duration
+
+	// RateLimitQPS and RateLimitBurst configure the overall token bucket all
+	// requests are subject to. A zero RateLimitQPS uses the default rate limiter.
+	RateLimitQPS   float64
+	RateLimitBurst int
+}
+
+// DefaultReconcilerOptions returns the options matching the defaults of controller-runtime.
+func DefaultReconcilerOptions() ReconcilerOptions {
+	return ReconcilerOptions{
+		MaxConcurrentReconciles: 1,
+		RateLimitBaseDelay:      5 * time.Millisecond,
+		RateLimitMaxDelay:       1000 * time.Second,
+		RateLimitQPS:            10,
+		RateLimitBurst:          100,
+	}
+}
+
+// controllerOptions converts o into options for a controller-runtime controller.
+func (o ReconcilerOptions) controllerOptions() runtimecontroller.Options {
+	opts := runtimecontroller.Options{
+		MaxConcurrentReconciles: o.MaxConcurrentReconciles,
+	}
+
+	if o.RateLimitQPS > 0 {
+		opts.RateLimiter = workqueue.NewTypedMaxOfRateLimiter(
+			workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](o.RateLimitBaseDelay, o.RateLimitMaxDelay),
+			&workqueue.TypedBucketRateLimiter[reconcile.Request]{Limiter: rate.NewLimiter(rate.Limit(o.RateLimitQPS), o.RateLimitBurst)},
+		)
+	}
+
+	return opts
+}
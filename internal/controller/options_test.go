@@ -0,0 +1,53 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_ReconcilerOptionsControllerOptions(t *testing.T) {
+	t.Run("zero value uses controller-runtime defaults", func(t *testing.T) {
+		opts := ReconcilerOptions{}.controllerOptions()
+		require.Zero(t, opts.MaxConcurrentReconciles)
+		require.Nil(t, opts.RateLimiter)
+	})
+
+	t.Run("configures workers and rate limiter", func(t *testing.T) {
+		opts := ReconcilerOptions{
+			MaxConcurrentReconciles: 4,
+			RateLimitBaseDelay:      time.Second,
+			RateLimitMaxDelay:       4 * time.Second,
+			RateLimitQPS:            1000,
+			RateLimitBurst:          1000,
+		}.controllerOptions()
+
+		require.Equal(t, 4, opts.MaxConcurrentReconciles)
+		require.NotNil(t, opts.RateLimiter)
+
+		req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "some-pod"}}
+		require.Equal(t, time.Second, opts.RateLimiter.When(req))
+		require.Equal(t, 2*time.Second, opts.RateLimiter.When(req))
+		require.Equal(t, 4*time.Second, opts.RateLimiter.When(req))
+		require.Equal(t, 4*time.Second, opts.RateLimiter.When(req), "delay must not exceed max delay")
+	})
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// RecreatePolicy decides how PodReconciler handles expired Pending pods whose owner
+// would immediately recreate them.
+type RecreatePolicy string
+
+const (
+	// RecreatePolicyDelete deletes the pods like any other pod. This is the zero value.
+	RecreatePolicyDelete RecreatePolicy = "delete"
+
+	// RecreatePolicySkip never deletes the pods.
+	RecreatePolicySkip RecreatePolicy = "skip"
+
+	// RecreatePolicyReport emits a warning event on expired pods instead of deleting them.
+	RecreatePolicyReport RecreatePolicy = "report"
+
+	// RecreatePolicyBackoff deletes the pods, but waits for an exponentially growing
+	// delay between two deletions of pods of the same owner.
+	RecreatePolicyBackoff RecreatePolicy = "backoff"
+)
+
+// ParseRecreatePolicy parses the name of a RecreatePolicy.
+func ParseRecreatePolicy(s string) (RecreatePolicy, error) {
+	switch p := RecreatePolicy(s); p {
+	case RecreatePolicyDelete, RecreatePolicySkip, RecreatePolicyReport, RecreatePolicyBackoff:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown recreate policy %q, must be one of delete, skip, report or backoff", s)
+	}
+}
+
+// recreatingOwnerKinds are the controllers which replace deleted Pending pods.
+var recreatingOwnerKinds = map[schema.GroupKind]bool{
+	{Group: "apps", Kind: "ReplicaSet"}:        true,
+	{Group: "apps", Kind: "StatefulSet"}:       true,
+	{Group: "apps", Kind: "DaemonSet"}:         true,
+	{Group: "", Kind: "ReplicationController"}: true,
+}
+
+// Reason of the warning event emitted on pods which are not deleted due to RecreatePolicyReport.
+const reasonRecreatedByOwner = "RecreatedByOwner"
+
+// Values of the action label of recreatablePodsTotal.
+const (
+	recreateActionReported = "reported"
+	recreateActionDeferred = "deferred"
+	recreateActionDeleted  = "deleted"
+)
+
+// +kubebuilder:rbac:groups=apps,resources=replicasets;statefulsets;daemonsets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=replicationcontrollers,verbs=get;list;watch
+
+// recreatingOwner returns the controller reference of pod if the RecreatePolicy applies
+// to it: The pod is Pending and its controller exists and would recreate it.
+func (r *PodReconciler) recreatingOwner(ctx context.Context, pod *v1.Pod) (*metav1.OwnerReference, error) {
+	if r.RecreatePolicy == "" || r.RecreatePolicy == RecreatePolicyDelete || pod.Status.Phase != v1.PodPending {
+		return nil, nil
+	}
+
+	ref := metav1.GetControllerOf(pod)
+	if ref == nil {
+		return nil, nil
+	}
+
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil || !recreatingOwnerKinds[gv.WithKind(ref.Kind).GroupKind()] {
+		return nil, nil
+	}
+
+	// Owners which are gone or being deleted do not recreate their pods
+	owner := &metav1.PartialObjectMetadata{}
+	owner.SetGroupVersionKind(gv.WithKind(ref.Kind))
+	if err := r.Get(ctx, client.ObjectKey{Namespace: pod.Namespace, Name: ref.Name}, owner); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get owner %s/%s: %w", ref.Kind, ref.Name, err)
+	}
+	if owner.UID != ref.UID || owner.DeletionTimestamp != nil {
+		return nil, nil
+	}
+
+	return ref, nil
+}
+
+// holdRecreatedPod applies the RecreatePolicy to an expired pod of a recreating owner.
+// It returns true if the pod must not be deleted now.
+func (r *PodReconciler) holdRecreatedPod(ctx context.Context, pod *v1.Pod, owner *metav1.OwnerReference) (ctrl.Result, bool, error) {
+	logger := log.FromContext(ctx)
+
+	switch r.RecreatePolicy {
+	case RecreatePolicyReport:
+		r.forgetPod(client.ObjectKeyFromObject(pod))
+		r.Recorder.Eventf(pod, v1.EventTypeWarning, reasonRecreatedByOwner,
+			"Pod has reached its max age but is not deleted since its owner %s/%s would recreate it", owner.Kind, owner.Name)
+		recreatablePodsTotal.WithLabelValues(recreateActionReported).Inc()
+		logger.Info("Not deleting pod which would be recreated by its owner", "owner", owner.Kind+"/"+owner.Name)
+		return ctrl.Result{}, true, r.removeDeleteAfter(ctx, pod)
+	case RecreatePolicyBackoff:
+		if wait := r.ownerBackoff.remaining(owner.UID, r.Clock.Now()); wait > 0 {
+			recreatablePodsTotal.WithLabelValues(recreateActionDeferred).Inc()
+			logger.V(1).Info("Deferring deletion of pod recreated by its owner", "owner", owner.Kind+"/"+owner.Name, "wait", wait)
+			return ctrl.Result{RequeueAfter: wait}, true, nil
+		}
+	}
+
+	return ctrl.Result{}, false, nil
+}
+
+// recordRecreatedPodDeletion starts or extends the backoff of owner after one of its pods was deleted.
+func (r *PodReconciler) recordRecreatedPodDeletion(owner *metav1.OwnerReference) {
+	recreatablePodsTotal.WithLabelValues(recreateActionDeleted).Inc()
+	r.ownerBackoff.record(owner.UID, r.Clock.Now(), r.RecreateBackoff, r.RecreateMaxBackoff)
+}
+
+// ownerBackoffTracker tracks the delay between two deletions of pods of the same owner.
+// The zero value is ready to use.
+type ownerBackoffTracker struct {
+	mu     sync.Mutex
+	owners map[types.UID]ownerBackoff
+}
+
+type ownerBackoff struct {
+	delay time.Duration
+	next  time.Time
+}
+
+// remaining returns the time until the next pod of the owner may be deleted.
+func (t *ownerBackoffTracker) remaining(owner types.UID, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if b, found := t.owners[owner]; found && now.Before(b.next) {
+		return b.next.Sub(now)
+	}
+	return 0
+}
+
+// record doubles the delay of the owner, starting with initial and capped at maxDelay. The delay
+// is reset once no pod of the owner has been deleted for maxDelay after the previous backoff.
+func (t *ownerBackoffTracker) record(owner types.UID, now time.Time, initial, maxDelay time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.owners == nil {
+		t.owners = make(map[types.UID]ownerBackoff)
+	}
+
+	// Forget owners whose delay would be reset anyway
+	for uid, b := range t.owners {
+		if now.Sub(b.next) >= maxDelay {
+			delete(t.owners, uid)
+		}
+	}
+
+	b, found := t.owners[owner]
+	if found {
+		b.delay = min(2*b.delay, maxDelay)
+	} else {
+		b.delay = initial
+	}
+	b.next = now.Add(b.delay)
+
+	t.owners[owner] = b
+}
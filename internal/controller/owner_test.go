@@ -0,0 +1,198 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_PodReconcilerRecreatePolicy(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	replicaSet := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-6d4cf56db6", UID: "rs-uid"}}
+
+	newPod := func(name string, phase v1.PodPhase) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "default",
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour)),
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "apps/v1",
+					Kind:       "ReplicaSet",
+					Name:       replicaSet.Name,
+					UID:        replicaSet.UID,
+					Controller: ptr.To(true),
+				}},
+			},
+			Status: v1.PodStatus{Phase: phase},
+		}
+	}
+
+	newReconciler := func(policy RecreatePolicy, objects ...client.Object) (*PodReconciler, *record.FakeRecorder) {
+		clk := clocktesting.NewFakeClock(now)
+		recorder := record.NewFakeRecorder(10)
+		return &PodReconciler{
+			Client:             fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build(),
+			Scheme:             scheme.Scheme,
+			Config:             NewPodReconcilerConfig(),
+			Clock:              clk,
+			Expiry:             NewExpiryScheduler(clk),
+			Recorder:           recorder,
+			RecreatePolicy:     policy,
+			RecreateBackoff:    time.Minute,
+			RecreateMaxBackoff: time.Hour,
+		}, recorder
+	}
+
+	reconcile := func(t *testing.T, r *PodReconciler, pod *v1.Pod) (ctrl.Result, bool) {
+		result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pod)})
+		require.NoError(t, err)
+		err = r.Get(context.Background(), client.ObjectKeyFromObject(pod), &v1.Pod{})
+		return result, apierrors.IsNotFound(err)
+	}
+
+	t.Run("skip", func(t *testing.T) {
+		pod := newPod("web-6d4cf56db6-abcde", v1.PodPending)
+		r, _ := newReconciler(RecreatePolicySkip, replicaSet, pod)
+
+		_, deleted := reconcile(t, r, pod)
+		require.False(t, deleted)
+	})
+
+	t.Run("skip deletes pods of deleted owners", func(t *testing.T) {
+		pod := newPod("web-6d4cf56db6-abcde", v1.PodPending)
+		r, _ := newReconciler(RecreatePolicySkip, pod)
+
+		_, deleted := reconcile(t, r, pod)
+		require.True(t, deleted)
+	})
+
+	t.Run("skip deletes failed pods", func(t *testing.T) {
+		pod := newPod("web-6d4cf56db6-abcde", v1.PodFailed)
+		r, _ := newReconciler(RecreatePolicySkip, replicaSet, pod)
+
+		_, deleted := reconcile(t, r, pod)
+		require.True(t, deleted)
+	})
+
+	t.Run("report", func(t *testing.T) {
+		pod := newPod("web-6d4cf56db6-abcde", v1.PodPending)
+		r, recorder := newReconciler(RecreatePolicyReport, replicaSet, pod)
+
+		_, deleted := reconcile(t, r, pod)
+		require.False(t, deleted)
+		require.Equal(t, "Warning RecreatedByOwner Pod has reached its max age but is not deleted since "+
+			"its owner ReplicaSet/web-6d4cf56db6 would recreate it", <-recorder.Events)
+	})
+
+	t.Run("report does not announce the deletion", func(t *testing.T) {
+		pod := newPod("web-6d4cf56db6-abcde", v1.PodPending)
+		pod.CreationTimestamp = metav1.NewTime(now.Add(-50 * time.Minute))
+		r, recorder := newReconciler(RecreatePolicyReport, replicaSet, pod)
+		r.Warnings = NewExpiryScheduler(r.Clock)
+		r.AnnotateDeleteAfter = true
+		r.WarningLeadTime = 30 * time.Minute
+
+		_, deleted := reconcile(t, r, pod)
+		require.False(t, deleted)
+		require.Empty(t, recorder.Events)
+
+		var current v1.Pod
+		require.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(pod), &current))
+		require.NotContains(t, current.Annotations, DeleteAfterAnnotation)
+	})
+
+	t.Run("report removes the delete-after annotation", func(t *testing.T) {
+		pod := newPod("web-6d4cf56db6-abcde", v1.PodPending)
+		pod.Annotations = map[string]string{DeleteAfterAnnotation: now.Add(-time.Hour).Format(time.RFC3339)}
+		r, _ := newReconciler(RecreatePolicyReport, replicaSet, pod)
+		r.AnnotateDeleteAfter = true
+
+		_, deleted := reconcile(t, r, pod)
+		require.False(t, deleted)
+
+		var current v1.Pod
+		require.NoError(t, r.Get(context.Background(), client.ObjectKeyFromObject(pod), &current))
+		require.NotContains(t, current.Annotations, DeleteAfterAnnotation)
+	})
+
+	t.Run("backoff", func(t *testing.T) {
+		first := newPod("web-6d4cf56db6-abcde", v1.PodPending)
+		second := newPod("web-6d4cf56db6-fghij", v1.PodPending)
+		r, _ := newReconciler(RecreatePolicyBackoff, replicaSet, first, second)
+
+		_, deleted := reconcile(t, r, first)
+		require.True(t, deleted)
+
+		// Further pods of the same owner wait for the backoff
+		result, deleted := reconcile(t, r, second)
+		require.False(t, deleted)
+		require.Equal(t, ctrl.Result{RequeueAfter: time.Minute}, result)
+
+		r.Clock.(*clocktesting.FakeClock).Step(time.Minute)
+		_, deleted = reconcile(t, r, second)
+		require.True(t, deleted)
+	})
+}
+
+func Test_OwnerBackoffTracker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	var tracker ownerBackoffTracker
+
+	require.Zero(t, tracker.remaining("a", now))
+
+	tracker.record("a", now, time.Minute, 3*time.Minute)
+	require.Equal(t, time.Minute, tracker.remaining("a", now))
+	require.Zero(t, tracker.remaining("b", now))
+
+	// The delay doubles up to the maximum
+	now = now.Add(time.Minute)
+	tracker.record("a", now, time.Minute, 3*time.Minute)
+	require.Equal(t, 2*time.Minute, tracker.remaining("a", now))
+
+	now = now.Add(2 * time.Minute)
+	tracker.record("a", now, time.Minute, 3*time.Minute)
+	require.Equal(t, 3*time.Minute, tracker.remaining("a", now))
+
+	// The delay is reset after a quiet period
+	now = now.Add(6 * time.Minute)
+	tracker.record("a", now, time.Minute, 3*time.Minute)
+	require.Equal(t, time.Minute, tracker.remaining("a", now))
+
+	// Owners are forgotten once their delay would be reset
+	now = now.Add(4 * time.Minute)
+	tracker.record("b", now, time.Minute, 3*time.Minute)
+	require.Len(t, tracker.owners, 1)
+	require.Contains(t, tracker.owners, types.UID("b"))
+}
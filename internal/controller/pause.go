@@ -0,0 +1,39 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+// pausedRecheckInterval is the time after which expired pods are re-evaluated while
+// podbouncer is paused. Configuration changes re-evaluate them immediately.
+const pausedRecheckInterval = 5 * time.Minute
+
+// PausedChecker returns a readiness check which fails while config is paused.
+func PausedChecker(config *PodReconcilerConfig) healthz.Checker {
+	return func(_ *http.Request) error {
+		if config.Paused() {
+			return errors.New("podbouncer is paused")
+		}
+		return nil
+	}
+}
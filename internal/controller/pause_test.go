@@ -0,0 +1,138 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_PodReconcilerPaused(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(now)
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "default",
+			Name:              "some-pod",
+			CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour)),
+		},
+		Status: v1.PodStatus{Phase: v1.PodFailed},
+	}
+	key := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
+	req := ctrl.Request{NamespacedName: key}
+
+	config := NewPodReconcilerConfig()
+	config.SetPaused(true)
+
+	r := &PodReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(pod).Build(),
+		Scheme: scheme.Scheme,
+		Config: config,
+		Clock:  clk,
+		Expiry: NewExpiryScheduler(clk),
+	}
+
+	// Expired pods are kept and re-evaluated on configuration changes
+	_, err := r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.NoError(t, r.Get(context.Background(), key, &v1.Pod{}))
+	require.Equal(t, []ctrl.Request{req}, r.candidateRequests(context.Background(), nil))
+
+	config.SetPaused(false)
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.True(t, apierrors.IsNotFound(r.Get(context.Background(), key, &v1.Pod{})))
+}
+
+func Test_PodSweeperPaused(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(now)
+
+	objects := []client.Object{
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "expired", CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour))},
+			Status:     v1.PodStatus{Phase: v1.PodFailed},
+		},
+	}
+
+	config := NewPodReconcilerConfig()
+	config.SetPaused(true)
+
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build()
+	s := &PodSweeper{Client: c, Config: config, Clock: clk}
+
+	summary, err := s.Sweep(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, SweepSummary{Namespaces: 1, Pods: 1, Candidates: 1, Expired: 1}, summary)
+
+	var remaining v1.PodList
+	require.NoError(t, c.List(context.Background(), &remaining))
+	require.Len(t, remaining.Items, 1, "paused sweeps must not delete pods")
+}
+
+func Test_PodReconcilerConfigPaused(t *testing.T) {
+	config := NewPodReconcilerConfig()
+	require.False(t, config.Paused())
+	require.NoError(t, PausedChecker(config)(nil))
+
+	// The gauge follows the configuration without any pod being reconciled
+	config.apply(configData{MaxPodAge: time.Hour, Paused: true})
+	require.True(t, config.Paused())
+	require.Equal(t, 1.0, testutil.ToFloat64(pausedGauge))
+	require.Error(t, PausedChecker(config)(nil))
+
+	config.SetPaused(false)
+	require.Equal(t, 0.0, testutil.ToFloat64(pausedGauge))
+
+	// A forced pause is kept regardless of the configuration
+	config.ForcePause()
+	require.Equal(t, 1.0, testutil.ToFloat64(pausedGauge))
+	config.SetPaused(false)
+	require.True(t, config.Paused())
+	require.Equal(t, 1.0, testutil.ToFloat64(pausedGauge))
+	require.Error(t, PausedChecker(config)(nil))
+}
+
+func Test_PodReconcilerConfigPauseUntilApplied(t *testing.T) {
+	config := NewPodReconcilerConfig()
+	config.PauseUntilApplied()
+	require.True(t, config.Paused())
+	require.Equal(t, 1.0, testutil.ToFloat64(pausedGauge))
+
+	// Applying the default values resumes as well
+	data := configData{MaxPodAge: time.Hour}
+	require.True(t, config.changedBy(data))
+	config.apply(data)
+	require.False(t, config.Paused())
+	require.Equal(t, 0.0, testutil.ToFloat64(pausedGauge))
+	require.False(t, config.changedBy(data))
+}
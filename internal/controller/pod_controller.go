@@ -21,14 +21,21 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // PodReconciler reconciles a Pod object
@@ -37,12 +44,89 @@ type PodReconciler struct {
 	Scheme *runtime.Scheme
 
 	Config *PodReconcilerConfig
+
+	// Clock is used to determine the age of pods. SetupWithManager uses the real clock if it is nil.
+	Clock clock.Clock
+
+	// ConfigChanges receives an event every time Config has been updated.
+	// All pods waiting for their max age are then re-evaluated immediately.
+	ConfigChanges <-chan event.GenericEvent
+
+	// Options configure the throughput of the controller.
+	Options ReconcilerOptions
+
+	// Shard restricts the reconciler to the namespaces owned by this replica.
+	// All namespaces are reconciled if it is nil.
+	Shard *ShardCoordinator
+
+	// PreDeleteHooks run before a pod is deleted.
+	PreDeleteHooks []PreDeleteHook
+
+	// Observers are notified about every deleted pod.
+	Observers []DeletionObserver
+
+	// ScheduleObservers are notified about every pod scheduled for deletion.
+	ScheduleObservers []ScheduleObserver
+
+	// Expiry tracks the pods waiting to reach their max age and triggers their
+	// reconciliation once they do. SetupWithManager creates it if it is nil.
+	Expiry *ExpiryScheduler
+
+	// AnnotateDeleteAfter enables setting DeleteAfterAnnotation on pods waiting to reach their max age.
+	AnnotateDeleteAfter bool
+
+	// WarningLeadTime is the time before deletion at which a warning event is emitted on the pod.
+	// No warnings are emitted if it is zero.
+	WarningLeadTime time.Duration
+
+	// Warnings tracks the pods waiting to enter the warning period and triggers their
+	// reconciliation once they do. SetupWithManager creates it if it is nil.
+	Warnings *ExpiryScheduler
+
+	// Recorder emits the warning events. SetupWithManager creates it if it is nil.
+	Recorder record.EventRecorder
+
+	// FinalizerTimeout is the time after which pods which are kept from terminating by
+	// their finalizers are counted as stuck. Stuck pods are not tracked if it is zero.
+	FinalizerTimeout time.Duration
+
+	// StaleFinalizers are removed from pods which are stuck for FinalizerTimeout.
+	StaleFinalizers []string
+
+	// DeleteFinishedJobs enables deleting the finished Job controlling an expired pod
+	// instead of the pod if all pods of the Job are expired, unless the Job is cleaned
+	// up by its ttlSecondsAfterFinished.
+	DeleteFinishedJobs bool
+
+	// RecreatePolicy decides how expired Pending pods are handled whose owner would
+	// immediately recreate them. They are deleted like any other pod if it is empty.
+	RecreatePolicy RecreatePolicy
+
+	// RecreateBackoff is the initial delay between two deletions of pods of the same owner
+	// with RecreatePolicyBackoff. The delay doubles with every deletion up to RecreateMaxBackoff.
+	RecreateBackoff    time.Duration
+	RecreateMaxBackoff time.Duration
+
+	// ChurnWindow and ChurnThreshold detect owners whose pods are deleted repeatedly: Once
+	// ChurnThreshold pods of the same owner have been deleted within ChurnWindow, a warning
+	// event is emitted on the owner. Churn is not tracked if either is zero.
+	ChurnWindow    time.Duration
+	ChurnThreshold int
+
+	// StopOnChurn disables deleting the pods of an owner while it is above the churn threshold.
+	StopOnChurn bool
+
+	warned       warningTracker
+	churn        churnTracker
+	ownerBackoff ownerBackoffTracker
+	stuck        stuckFinalizerTracker
 }
 
 const excludedNamespace = "kube-system"
 
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=pods/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -54,58 +138,147 @@ func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.R
 		return ctrl.Result{}, nil
 	}
 
+	// Ignore pods which are owned by another replica
+	if r.Shard != nil && !r.Shard.OwnsNamespace(req.Namespace) {
+		r.forgetPod(req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+
 	// Retrieve pod
 	var pod v1.Pod
 	if err := r.Get(ctx, req.NamespacedName, &pod); err != nil {
+		r.forgetPod(req.NamespacedName)
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// Pods which have already been deleted are not deleted again
+	if pod.DeletionTimestamp != nil {
+		r.forgetPod(req.NamespacedName)
+		return r.reconcileTerminating(ctx, &pod)
+	}
+
+	decision, err := EvaluatePod(&pod, r.Config, r.Clock.Now())
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	recordDecision(decision)
+
 	// Ignore pods which not in a phase where they should be deleted
-	if !r.shouldDeletePod(&pod) {
-		return ctrl.Result{}, nil
+	if !decision.Candidate {
+		r.forgetPod(req.NamespacedName)
+		return ctrl.Result{}, r.removeDeleteAfter(ctx, &pod)
+	}
+
+	// Ignore pods whose owner would recreate them if the RecreatePolicy says so
+	owner, err := r.recreatingOwner(ctx, &pod)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if owner != nil && r.RecreatePolicy == RecreatePolicySkip {
+		r.forgetPod(req.NamespacedName)
+		return ctrl.Result{}, r.removeDeleteAfter(ctx, &pod)
 	}
 
 	// Ignore pods which have not yet reached the deletion deadline
-	// TODO: Refactor into testable function?
-	podCreatedAt := pod.GetCreationTimestamp()
-	if podCreatedAt.IsZero() {
-		return ctrl.Result{}, fmt.Errorf("pod creation timestamp has unexpected zero value")
+	if !decision.Expired {
+		// Pod is not yet ready for deletion - the expiry scheduler runs reconciliation again
+		// once it reaches its max age. If the PodReconcilerConfig changes in the meantime,
+		// all scheduled pods are re-evaluated and their deadlines are updated.
+		if r.Expiry.Schedule(req.NamespacedName, decision.ExpiresAt) {
+			observeSchedule(ctx, r.ScheduleObservers, &pod, decision)
+		}
+		// Pods which are reported instead of deleted are not announced to be deleted
+		if owner != nil && r.RecreatePolicy == RecreatePolicyReport {
+			return ctrl.Result{}, r.removeDeleteAfter(ctx, &pod)
+		}
+		r.warnPendingDeletion(&pod, decision)
+		return ctrl.Result{}, r.annotateDeleteAfter(ctx, &pod, decision)
 	}
 
-	podAge := time.Since(podCreatedAt.Time)
-	maxPodAge := r.Config.MaxPodAge()
-	if podAge < maxPodAge {
-		// Pod is not yet read for deletion - run reconciliation again in one minute.
-		// We could wait the exact duration after which the object is reaches its max age
-		// (maxPodAge - podAge) but then this logic would not properly react to changes
-		// in the PodReconcilerConfig. To react to config updates, simply requeue within one minute
-		// (which is a sensible delay for the operator to react to a config update) or less,
-		// if the pod expires before that.
-		requeueAfter := minDuration(time.Minute, maxPodAge+time.Second)
-		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	if owner != nil {
+		if result, hold, err := r.holdRecreatedPod(ctx, &pod, owner); hold || err != nil {
+			return result, err
+		}
 	}
 
-	logger.Info("Deleting non-running pod", "phase", pod.Status.Phase, "podAge", podAge, "maxPodAge", maxPodAge)
+	// Keep pods of churning owners until enough deletions have left the churn window
+	if until, churning := r.churningUntil(&pod); churning && r.StopOnChurn {
+		logger.V(1).Info("Not deleting pod of churning owner", "until", until)
+		r.Expiry.Schedule(req.NamespacedName, until)
+		return ctrl.Result{}, nil
+	}
 
-	if err := r.Delete(ctx, &pod); err != nil {
+	// Defer deletions outside of the maintenance windows to the next opening
+	if windows := r.Config.MaintenanceWindows(); !windows.Open(r.Clock.Now()) {
+		return r.deferDeletion(ctx, &pod, decision, windows)
+	}
+
+	// Keep expired pods while paused. They are scheduled again, so a configuration change
+	// resuming podbouncer deletes them right away.
+	if r.Config.Paused() {
+		logger.V(1).Info("Not deleting pod while paused")
+		r.Expiry.Schedule(req.NamespacedName, r.Clock.Now().Add(pausedRecheckInterval))
+		return ctrl.Result{}, nil
+	}
+
+	logger.Info("Deleting non-running pod", "phase", pod.Status.Phase, "podAge", decision.Age, "maxPodAge", decision.MaxPodAge)
+
+	deleted, pods, err := deleteExpiredPod(ctx, r.Client, r.PreDeleteHooks, expiredPod{pod: &pod, decision: decision},
+		r.Config, r.Clock.Now(), r.DeleteFinishedJobs)
+	if apierrors.IsNotFound(err) {
+		// Deleted by someone else in the meantime, there is nothing to record
+		r.forgetPod(req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("failed to delete pod: %w", err)
 	}
 
-	logger.Info("Pod deleted")
+	r.forgetPod(req.NamespacedName)
+	for _, p := range pods {
+		observeDeletion(ctx, r.Observers, p.pod, p.decision, false)
+	}
+	if owner != nil {
+		r.recordRecreatedPodDeletion(owner)
+	}
+	r.recordChurn(ctx, &pod)
+
+	if job, ok := deleted.(*batchv1.Job); ok {
+		logger.Info("Job deleted", "job", job.Name, "pods", len(pods))
+	} else {
+		logger.Info("Pod deleted")
+	}
 
 	return ctrl.Result{}, nil
 }
 
-func minDuration(a, b time.Duration) time.Duration {
-	if a < b {
-		return a
+// candidateRequests maps a config change event to reconcile requests for all pods
+// waiting to reach their max age.
+func (r *PodReconciler) candidateRequests(_ context.Context, _ client.Object) []ctrl.Request {
+	return r.Expiry.Requests()
+}
+
+// deletePod deletes pod and records the deletion metrics.
+func deletePod(ctx context.Context, c client.Client, pod *v1.Pod) error {
+	timer := prometheus.NewTimer(podDeletionDuration)
+	defer timer.ObserveDuration()
+
+	if err := c.Delete(ctx, pod); err != nil {
+		return err
 	}
-	return b
+
+	podsDeletedTotal.WithLabelValues(string(pod.Status.Phase)).Inc()
+	return nil
+}
+
+// shardRequests maps a shard membership change to reconcile requests for all pods
+// of the namespaces owned by this replica.
+func (r *PodReconciler) shardRequests(ctx context.Context, _ client.Object) []ctrl.Request {
+	return r.Shard.ownedPodRequests(ctx, r.Client)
 }
 
 func (r *PodReconciler) shouldDeletePod(pod *v1.Pod) bool {
-	phase := pod.Status.Phase
-	return phase == v1.PodPending || phase == v1.PodSucceeded || phase == v1.PodFailed
+	return isCandidatePhase(pod.Status.Phase)
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -129,9 +302,47 @@ func (r *PodReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		},
 	}
 
-	return ctrl.NewControllerManagedBy(mgr).
+	if r.Clock == nil {
+		r.Clock = clock.RealClock{}
+	}
+	if r.Expiry == nil {
+		r.Expiry = NewExpiryScheduler(r.Clock)
+	}
+	if err := mgr.Add(r.Expiry); err != nil {
+		return fmt.Errorf("failed to add expiry scheduler: %w", err)
+	}
+	if r.Warnings == nil {
+		r.Warnings = newExpiryScheduler(r.Clock, pendingWarnings)
+	}
+	if err := mgr.Add(r.Warnings); err != nil {
+		return fmt.Errorf("failed to add warning scheduler: %w", err)
+	}
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorderFor("podbouncer")
+	}
+	if r.churnEnabled() {
+		if err := mgr.Add(manager.RunnableFunc(r.pruneChurn)); err != nil {
+			return fmt.Errorf("failed to add churn pruning: %w", err)
+		}
+	}
+
+	b := ctrl.NewControllerManagedBy(mgr).
 		Watches(&v1.Pod{}, &handler.EnqueueRequestForObject{}).
+		WatchesRawSource(source.Channel(r.Expiry.Events(), &handler.EnqueueRequestForObject{})).
+		WatchesRawSource(source.Channel(r.Warnings.Events(), &handler.EnqueueRequestForObject{})).
 		WithEventFilter(p).
-		Named("pod").
-		Complete(r)
+		WithOptions(r.Options.controllerOptions()).
+		Named("pod")
+
+	maxConcurrentReconciles.WithLabelValues("pod").Set(float64(r.Options.MaxConcurrentReconciles))
+
+	if r.ConfigChanges != nil {
+		b = b.WatchesRawSource(source.Channel(r.ConfigChanges, handler.EnqueueRequestsFromMapFunc(r.candidateRequests)))
+	}
+
+	if r.Shard != nil {
+		b = b.WatchesRawSource(source.Channel(r.Shard.Changes(), handler.EnqueueRequestsFromMapFunc(r.shardRequests)))
+	}
+
+	return b.Complete(r)
 }
@@ -17,12 +17,21 @@ limitations under the License.
 package controller
 
 import (
+	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 var _ = Describe("Pod Controller", func() {
@@ -73,3 +82,150 @@ func Test_PodReconcilerShouldDeletePod(t *testing.T) {
 		})
 	}
 }
+
+func Test_PodReconcilerReconcile(t *testing.T) {
+	type Test struct {
+		Name      string
+		Namespace string
+		Phase     v1.PodPhase
+		Age       time.Duration
+		MaxPodAge time.Duration
+
+		ExpectDeleted   bool
+		ExpectScheduled time.Duration // Expected remaining time, zero if the pod should not be scheduled
+	}
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []Test{
+		{Name: "young pending pod", Phase: v1.PodPending, Age: time.Minute, MaxPodAge: time.Hour, ExpectScheduled: 59 * time.Minute},
+		{Name: "pending pod before max age", Phase: v1.PodPending, Age: time.Hour - time.Second, MaxPodAge: time.Hour, ExpectScheduled: time.Second},
+		{Name: "pending pod at max age", Phase: v1.PodPending, Age: time.Hour, MaxPodAge: time.Hour, ExpectDeleted: true},
+		{Name: "pending pod after max age", Phase: v1.PodPending, Age: time.Hour + time.Second, MaxPodAge: time.Hour, ExpectDeleted: true},
+		{Name: "succeeded pod before max age", Phase: v1.PodSucceeded, Age: time.Hour - time.Second, MaxPodAge: time.Hour, ExpectScheduled: time.Second},
+		{Name: "succeeded pod at max age", Phase: v1.PodSucceeded, Age: time.Hour, MaxPodAge: time.Hour, ExpectDeleted: true},
+		{Name: "failed pod before max age", Phase: v1.PodFailed, Age: time.Hour - time.Second, MaxPodAge: time.Hour, ExpectScheduled: time.Second},
+		{Name: "failed pod at max age", Phase: v1.PodFailed, Age: time.Hour, MaxPodAge: time.Hour, ExpectDeleted: true},
+		{Name: "failed pod with zero max age", Phase: v1.PodFailed, Age: 0, MaxPodAge: 0, ExpectDeleted: true},
+		{Name: "running pod after max age", Phase: v1.PodRunning, Age: 2 * time.Hour, MaxPodAge: time.Hour},
+		{Name: "unknown pod after max age", Phase: v1.PodUnknown, Age: 2 * time.Hour, MaxPodAge: time.Hour},
+		{Name: "failed pod in excluded namespace", Namespace: excludedNamespace, Phase: v1.PodFailed, Age: 2 * time.Hour, MaxPodAge: time.Hour},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			namespace := test.Namespace
+			if namespace == "" {
+				namespace = "default"
+			}
+
+			pod := &v1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:         namespace,
+					Name:              "some-pod",
+					CreationTimestamp: metav1.NewTime(now.Add(-test.Age)),
+				},
+				Status: v1.PodStatus{Phase: test.Phase},
+			}
+			key := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
+
+			clk := clocktesting.NewFakeClock(now)
+			config := NewPodReconcilerConfig()
+			config.SetMaxPodAge(test.MaxPodAge)
+
+			c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(pod).Build()
+			r := &PodReconciler{
+				Client: c,
+				Scheme: scheme.Scheme,
+				Config: config,
+				Clock:  clk,
+				Expiry: NewExpiryScheduler(clk),
+			}
+
+			result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+			require.NoError(t, err)
+			require.Equal(t, ctrl.Result{}, result)
+
+			err = c.Get(context.Background(), key, &v1.Pod{})
+			require.Equal(t, test.ExpectDeleted, apierrors.IsNotFound(err), "unexpected deletion state")
+
+			remaining, scheduled := r.Expiry.Remaining(key)
+			require.Equal(t, test.ExpectScheduled != 0, scheduled, "unexpected scheduling state")
+			require.Equal(t, test.ExpectScheduled, remaining)
+		})
+	}
+
+	t.Run("ignores missing pod", func(t *testing.T) {
+		clk := clocktesting.NewFakeClock(now)
+		r := &PodReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(),
+			Scheme: scheme.Scheme,
+			Config: NewPodReconcilerConfig(),
+			Clock:  clk,
+			Expiry: NewExpiryScheduler(clk),
+		}
+
+		key := types.NamespacedName{Namespace: "default", Name: "missing"}
+		r.Expiry.Schedule(key, now)
+
+		result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+		require.NoError(t, err)
+		require.Equal(t, ctrl.Result{}, result)
+		require.Empty(t, r.Expiry.Requests(), "missing pod should no longer be scheduled")
+	})
+}
+
+func Test_PodReconcilerTracksCandidates(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(now)
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "default",
+			Name:              "some-pod",
+			CreationTimestamp: metav1.NewTime(now.Add(-10 * time.Minute)),
+		},
+		Status: v1.PodStatus{Phase: v1.PodFailed},
+	}
+	key := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
+
+	schedules := &recordingScheduleObserver{}
+	r := &PodReconciler{
+		Client:            fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(pod).Build(),
+		Scheme:            scheme.Scheme,
+		Config:            NewPodReconcilerConfig(),
+		Clock:             clk,
+		Expiry:            NewExpiryScheduler(clk),
+		ScheduleObservers: []ScheduleObserver{schedules},
+	}
+
+	// Pod is scheduled to expire exactly when it reaches its max age
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.Equal(t, ctrl.Result{}, result)
+
+	// Observers are only notified when the deadline changes
+	_, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.Equal(t, []time.Time{now.Add(50 * time.Minute)}, schedules.deadlines)
+
+	remaining, found := r.Expiry.Remaining(key)
+	require.True(t, found)
+	require.Equal(t, 50*time.Minute, remaining)
+	require.Equal(t, []ctrl.Request{{NamespacedName: key}}, r.candidateRequests(context.Background(), nil))
+
+	// After a config change, the re-evaluated pod is deleted and no longer tracked
+	r.Config.SetMaxPodAge(5 * time.Minute)
+	result, err = r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	require.NoError(t, err)
+	require.Equal(t, ctrl.Result{}, result)
+	require.Empty(t, r.candidateRequests(context.Background(), nil))
+}
+
+type recordingScheduleObserver struct {
+	deadlines []time.Time
+}
+
+func (o *recordingScheduleObserver) ObserveSchedule(_ context.Context, _ *v1.Pod, decision PodDecision) {
+	o.deadlines = append(o.deadlines, decision.ExpiresAt.UTC())
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// KeepAnnotation excludes a pod from deletion if its value is "true".
+const KeepAnnotation = "podbouncer.io/keep"
+
+// PodDecision is the result of evaluating a pod against a PodReconcilerConfig.
+type PodDecision struct {
+	// Candidate is true if the pod is deleted once it reaches its max age.
+	Candidate bool
+
+	// Expired is true if the pod is a candidate which has reached its max age
+	// and should be deleted now.
+	Expired bool
+
+	// Rule describes why the pod is or is not a candidate.
+	Rule string
+
+	Age       time.Duration
+	MaxPodAge time.Duration
+
+	// ExpiresAt is the time at which a candidate reaches its max age.
+	ExpiresAt time.Time
+}
+
+// EvaluatePod decides what should happen to pod at the given point in time.
+//
+// This is the single place of the deletion policy. It is shared by PodReconciler,
+// PodSweeper and the command line tools.
+func EvaluatePod(pod *v1.Pod, config *PodReconcilerConfig, now time.Time) (PodDecision, error) {
+	decision := PodDecision{MaxPodAge: config.MaxPodAge()}
+
+	if pod.Namespace == excludedNamespace {
+		decision.Rule = fmt.Sprintf("namespace=%s excluded", excludedNamespace)
+		return decision, nil
+	}
+
+	if pod.DeletionTimestamp != nil {
+		decision.Rule = "terminating"
+		return decision, nil
+	}
+
+	if keep, _ := strconv.ParseBool(pod.Annotations[KeepAnnotation]); keep {
+		decision.Rule = fmt.Sprintf("annotation %s=true", KeepAnnotation)
+		return decision, nil
+	}
+
+	if !isCandidatePhase(pod.Status.Phase) {
+		decision.Rule = fmt.Sprintf("phase=%s ignored", pod.Status.Phase)
+		return decision, nil
+	}
+
+	podCreatedAt := pod.GetCreationTimestamp()
+	if podCreatedAt.IsZero() {
+		return decision, fmt.Errorf("pod creation timestamp has unexpected zero value")
+	}
+
+	decision.Candidate = true
+	decision.Rule = fmt.Sprintf("phase=%s", pod.Status.Phase)
+	decision.Age = now.Sub(podCreatedAt.Time)
+	decision.ExpiresAt = podCreatedAt.Add(decision.MaxPodAge)
+	decision.Expired = decision.Age >= decision.MaxPodAge
+
+	return decision, nil
+}
+
+// isCandidatePhase returns true if pods in the given phase are deleted once they reach their max age.
+func isCandidatePhase(phase v1.PodPhase) bool {
+	return phase == v1.PodPending || phase == v1.PodSucceeded || phase == v1.PodFailed
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_EvaluatePod(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	config := NewPodReconcilerConfig()
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "some-pod", CreationTimestamp: metav1.NewTime(now.Add(-10 * time.Minute))},
+		Status:     v1.PodStatus{Phase: v1.PodFailed},
+	}
+
+	decision, err := EvaluatePod(pod, config, now)
+	require.NoError(t, err)
+	require.Equal(t, PodDecision{
+		Candidate: true,
+		Rule:      "phase=Failed",
+		Age:       10 * time.Minute,
+		MaxPodAge: time.Hour,
+		ExpiresAt: now.Add(50 * time.Minute),
+	}, decision)
+
+	t.Run("rejects zero creation timestamp", func(t *testing.T) {
+		_, err := EvaluatePod(&v1.Pod{Status: v1.PodStatus{Phase: v1.PodFailed}}, config, now)
+		require.Error(t, err)
+	})
+
+	t.Run("ignores running pods", func(t *testing.T) {
+		running := pod.DeepCopy()
+		running.Status.Phase = v1.PodRunning
+
+		decision, err := EvaluatePod(running, config, now)
+		require.NoError(t, err)
+		require.False(t, decision.Candidate)
+		require.Equal(t, "phase=Running ignored", decision.Rule)
+	})
+
+	t.Run("keeps annotated pods", func(t *testing.T) {
+		kept := pod.DeepCopy()
+		kept.Annotations = map[string]string{KeepAnnotation: "true"}
+
+		decision, err := EvaluatePod(kept, config, now)
+		require.NoError(t, err)
+		require.False(t, decision.Candidate)
+		require.Equal(t, "annotation podbouncer.io/keep=true", decision.Rule)
+
+		kept.Annotations[KeepAnnotation] = "false"
+		decision, err = EvaluatePod(kept, config, now)
+		require.NoError(t, err)
+		require.True(t, decision.Candidate)
+	})
+
+	t.Run("ignores terminating pods", func(t *testing.T) {
+		terminating := pod.DeepCopy()
+		terminating.DeletionTimestamp = &metav1.Time{Time: now}
+
+		decision, err := EvaluatePod(terminating, config, now)
+		require.NoError(t, err)
+		require.False(t, decision.Candidate)
+		require.Equal(t, "terminating", decision.Rule)
+	})
+}
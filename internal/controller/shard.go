@@ -0,0 +1,313 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/clock"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// shardMemberLabel marks the Leases used for shard membership.
+	shardMemberLabel = "podbouncer.io/shard-member"
+
+	shardLeasePrefix = "podbouncer-shard-"
+)
+
+// ShardCoordinator distributes the ownership of namespaces across all active replicas.
+//
+// Every replica maintains its own Lease. All replicas with a valid Lease are members of
+// the shard ring and every namespace is owned by exactly one member, determined by
+// rendezvous hashing. When replicas come and go, only the namespaces of the affected
+// replica move to other members.
+//
+// A replica which could not renew its Lease within LeaseDuration stops processing any
+// namespace, since the other replicas consider it gone and take over its namespaces.
+// Until all replicas have synced a membership change, two replicas may still briefly
+// process the same namespace. Pre-delete hooks may then run twice for a pod, but only
+// the replica whose delete request succeeds reports the deletion to the observers.
+//
+// Sharding replaces leader election: All replicas are active at the same time.
+type ShardCoordinator struct {
+	// Client is used to write Leases, Reader to list them (bypassing the cache).
+	Client client.Client
+	Reader client.Reader
+
+	// Namespace is the namespace the Leases are stored in.
+	Namespace string
+
+	// Identity uniquely identifies this replica, e.g. its pod name.
+	Identity string
+
+	// LeaseDuration is the time after which the Lease of a replica which stopped renewing expires.
+	LeaseDuration time.Duration
+
+	// RenewInterval is the time between two renewals of the own Lease.
+	RenewInterval time.Duration
+
+	// Clock is used to renew and expire Leases. The real clock is used if it is nil.
+	Clock clock.Clock
+
+	mu      sync.Mutex
+	members []string
+
+	// renewedAt is the time the own Lease was last renewed
+	renewedAt time.Time
+
+	changes chan event.GenericEvent
+}
+
+// Access to Leases is granted by the leader election Role (config/rbac/leader_election_role.yaml).
+
+// OwnsNamespace returns true if this replica is responsible for the given namespace.
+//
+// Until the first membership sync succeeded and whenever the own Lease has not been
+// renewed within LeaseDuration, no namespace is owned.
+func (c *ShardCoordinator) OwnsNamespace(namespace string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.leaseHeld(c.clock().Now()) {
+		return false
+	}
+	return shardOwner(c.members, namespace) == c.Identity
+}
+
+// leaseHeld reports whether the own Lease is still valid at now. c.mu must be held.
+func (c *ShardCoordinator) leaseHeld(now time.Time) bool {
+	return !c.renewedAt.IsZero() && now.Sub(c.renewedAt) < c.LeaseDuration
+}
+
+// Members returns the identities of all active replicas.
+func (c *ShardCoordinator) Members() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return slices.Clone(c.members)
+}
+
+// Changes returns the channel on which an event is emitted every time the members change.
+func (c *ShardCoordinator) Changes() <-chan event.GenericEvent {
+	return c.changesChan()
+}
+
+func (c *ShardCoordinator) changesChan() chan event.GenericEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.changes == nil {
+		c.changes = make(chan event.GenericEvent, 1)
+	}
+	return c.changes
+}
+
+// Start renews the own Lease and syncs the members every RenewInterval until ctx is done.
+// The own Lease is deleted on shutdown, so that other replicas take over immediately.
+func (c *ShardCoordinator) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("shard").WithValues("identity", c.Identity)
+
+	if c.RenewInterval <= 0 || c.LeaseDuration <= c.RenewInterval {
+		return fmt.Errorf("shard lease duration %s must be greater than renew interval %s", c.LeaseDuration, c.RenewInterval)
+	}
+
+	next := c.clock().NewTimer(0)
+	defer next.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			c.release(logger)
+			return nil
+		case <-next.C():
+		}
+
+		changed, err := c.sync(ctx)
+		if err != nil {
+			// Log error but keep trying - the Lease expires if renewals keep failing
+			logger.Error(err, "Failed to sync shard members")
+		}
+		if changed {
+			logger.Info("Shard members changed", "members", c.Members())
+		}
+
+		next.Reset(c.RenewInterval)
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Every replica is a shard member.
+func (c *ShardCoordinator) NeedLeaderElection() bool {
+	return false
+}
+
+// sync renews the own Lease and updates the members. It returns true if the members changed.
+func (c *ShardCoordinator) sync(ctx context.Context) (bool, error) {
+	now := c.clock().Now()
+
+	if err := c.renew(ctx, now); err != nil {
+		return false, fmt.Errorf("failed to renew lease: %w", err)
+	}
+
+	var leases coordinationv1.LeaseList
+	if err := c.Reader.List(ctx, &leases, client.InNamespace(c.Namespace), client.MatchingLabels{shardMemberLabel: "true"}); err != nil {
+		return false, fmt.Errorf("failed to list leases: %w", err)
+	}
+
+	members := []string{c.Identity}
+	for _, lease := range leases.Items {
+		if !leaseValid(&lease, now) || lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity == c.Identity {
+			continue
+		}
+		members = append(members, *lease.Spec.HolderIdentity)
+	}
+	slices.Sort(members)
+
+	// Regaining the own Lease is a change as well, the namespaces have not been processed meanwhile
+	c.mu.Lock()
+	changed := !slices.Equal(c.members, members) || !c.leaseHeld(now)
+	c.members = members
+	c.renewedAt = now
+	c.mu.Unlock()
+
+	if changed {
+		c.notify()
+	}
+
+	return changed, nil
+}
+
+// renew creates or updates the own Lease.
+func (c *ShardCoordinator) renew(ctx context.Context, now time.Time) error {
+	lease := &coordinationv1.Lease{}
+	key := client.ObjectKey{Namespace: c.Namespace, Name: shardLeasePrefix + c.Identity}
+
+	err := c.Reader.Get(ctx, key, lease)
+	if apierrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: key.Namespace,
+				Name:      key.Name,
+				Labels:    map[string]string{shardMemberLabel: "true"},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       ptr.To(c.Identity),
+				LeaseDurationSeconds: ptr.To(int32(c.LeaseDuration.Seconds())),
+				AcquireTime:          ptr.To(metav1.NewMicroTime(now)),
+				RenewTime:            ptr.To(metav1.NewMicroTime(now)),
+			},
+		}
+		return c.Client.Create(ctx, lease)
+	}
+	if err != nil {
+		return err
+	}
+
+	lease.Spec.HolderIdentity = ptr.To(c.Identity)
+	lease.Spec.LeaseDurationSeconds = ptr.To(int32(c.LeaseDuration.Seconds()))
+	lease.Spec.RenewTime = ptr.To(metav1.NewMicroTime(now))
+	return c.Client.Update(ctx, lease)
+}
+
+// release deletes the own Lease.
+func (c *ShardCoordinator) release(logger logr.Logger) {
+	// The manager context is already cancelled, use a short-lived one instead
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	lease := &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Namespace: c.Namespace, Name: shardLeasePrefix + c.Identity}}
+	if err := c.Client.Delete(ctx, lease); client.IgnoreNotFound(err) != nil {
+		logger.Error(err, "Failed to release lease")
+	}
+}
+
+// notify emits a change event without blocking.
+func (c *ShardCoordinator) notify() {
+	changes := c.changesChan()
+
+	lease := &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Namespace: c.Namespace, Name: shardLeasePrefix + c.Identity}}
+	select {
+	case changes <- event.GenericEvent{Object: lease}:
+	default:
+	}
+}
+
+func (c *ShardCoordinator) clock() clock.Clock {
+	if c.Clock == nil {
+		return clock.RealClock{}
+	}
+	return c.Clock
+}
+
+// ownedPodRequests returns reconcile requests for all cached pods in namespaces owned by c.
+func (c *ShardCoordinator) ownedPodRequests(ctx context.Context, reader client.Reader) []ctrl.Request {
+	var pods v1.PodList
+	if err := reader.List(ctx, &pods); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list pods after shard rebalancing")
+		return nil
+	}
+
+	var requests []ctrl.Request
+	for _, pod := range pods.Items {
+		if c.OwnsNamespace(pod.Namespace) {
+			requests = append(requests, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&pod)})
+		}
+	}
+	return requests
+}
+
+// leaseValid returns true if lease has been renewed within its duration.
+func leaseValid(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return false
+	}
+
+	expiresAt := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return now.Before(expiresAt)
+}
+
+// shardOwner returns the member owning namespace using rendezvous hashing,
+// or an empty string if there are no members.
+func shardOwner(members []string, namespace string) string {
+	var owner string
+	var best uint64
+
+	for _, member := range members {
+		sum := sha256.Sum256([]byte(member + "/" + namespace))
+
+		if score := binary.BigEndian.Uint64(sum[:8]); owner == "" || score > best {
+			owner, best = member, score
+		}
+	}
+
+	return owner
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_ShardOwnerRebalancesMinimally(t *testing.T) {
+	members := []string{"a", "b", "c"}
+
+	owners := make(map[string]string)
+	counts := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		namespace := fmt.Sprintf("namespace-%d", i)
+		owners[namespace] = shardOwner(members, namespace)
+		counts[owners[namespace]]++
+	}
+
+	for _, member := range members {
+		require.Greater(t, counts[member], 50, "namespaces should be spread across members")
+	}
+
+	// Removing a member only moves the namespaces owned by it
+	for namespace, owner := range owners {
+		newOwner := shardOwner([]string{"a", "c"}, namespace)
+		if owner != "b" {
+			require.Equal(t, owner, newOwner)
+		} else {
+			require.NotEqual(t, "b", newOwner)
+		}
+	}
+
+	require.Equal(t, "", shardOwner(nil, "default"))
+}
+
+func Test_ShardCoordinatorSync(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(now)
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+
+	coordinator := func(identity string) *ShardCoordinator {
+		return &ShardCoordinator{
+			Client:        c,
+			Reader:        c,
+			Namespace:     "podbouncer-system",
+			Identity:      identity,
+			LeaseDuration: 15 * time.Second,
+			RenewInterval: 5 * time.Second,
+			Clock:         clk,
+		}
+	}
+	a := coordinator("a")
+	b := coordinator("b")
+
+	require.False(t, a.OwnsNamespace("default"), "nothing is owned before the first sync")
+
+	changed, err := a.sync(ctx)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, []string{"a"}, a.Members())
+	require.True(t, a.OwnsNamespace("default"))
+
+	_, err = b.sync(ctx)
+	require.NoError(t, err)
+	changed, err = a.sync(ctx)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Len(t, a.Changes(), 1, "change event should be pending")
+
+	require.Equal(t, []string{"a", "b"}, a.Members())
+	require.Equal(t, []string{"a", "b"}, b.Members())
+	for i := 0; i < 20; i++ {
+		namespace := fmt.Sprintf("namespace-%d", i)
+		require.NotEqual(t, a.OwnsNamespace(namespace), b.OwnsNamespace(namespace), "namespace must have exactly one owner")
+	}
+
+	// Renewing without membership changes is not reported
+	clk.Step(5 * time.Second)
+	changed, err = a.sync(ctx)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	// b stops renewing and its lease expires
+	clk.Step(15 * time.Second)
+	changed, err = a.sync(ctx)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, []string{"a"}, a.Members())
+	for i := 0; i < 20; i++ {
+		require.True(t, a.OwnsNamespace(fmt.Sprintf("namespace-%d", i)))
+		require.False(t, b.OwnsNamespace(fmt.Sprintf("namespace-%d", i)), "b must stop once its lease expired")
+	}
+
+	// b renews its lease again and takes over its namespaces
+	changed, err = b.sync(ctx)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, []string{"a", "b"}, b.Members())
+}
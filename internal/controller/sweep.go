@@ -0,0 +1,307 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// PodSweeper deletes expired pods in periodic batches.
+//
+// It is an alternative to the event driven PodReconciler: Instead of reacting to every
+// pod event, all candidate pods are listed from the cache once per Interval, evaluated
+// and expired pods are deleted with bounded concurrency.
+type PodSweeper struct {
+	client.Client
+
+	Config *PodReconcilerConfig
+
+	// Clock is used to determine the age of pods. The real clock is used if it is nil.
+	Clock clock.Clock
+
+	// Interval is the time between two sweeps.
+	Interval time.Duration
+
+	// Concurrency is the maximum number of concurrent deletions. Values below 1 are treated as 1.
+	Concurrency int
+
+	// DryRun disables deletions. Expired pods are only counted and logged.
+	DryRun bool
+
+	// PreDeleteHooks run before a pod is deleted.
+	PreDeleteHooks []PreDeleteHook
+
+	// Observers are notified about every deleted pod.
+	Observers []DeletionObserver
+
+	// DeleteFinishedJobs enables deleting the finished Job controlling an expired pod
+	// instead of the pod if all pods of the Job are expired, unless the Job is cleaned
+	// up by its ttlSecondsAfterFinished.
+	DeleteFinishedJobs bool
+
+	// Shard restricts the sweeper to the namespaces owned by this replica.
+	// All namespaces are swept if it is nil.
+	Shard *ShardCoordinator
+}
+
+// SweepSummary contains the results of a single sweep.
+type SweepSummary struct {
+	Namespaces int
+	Pods       int
+	Candidates int
+	Expired    int
+	Deferred   int // Expired pods kept since no maintenance window is open
+	Deleted    int // Always zero in dry run mode and while paused
+	Failed     int
+}
+
+// ErrListFailed is wrapped by the error of a sweep which could not list the namespaces
+// or pods. Such a sweep has not deleted any pod.
+var ErrListFailed = errors.New("failed to list")
+
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+
+// Start runs a sweep every Interval until ctx is done.
+func (s *PodSweeper) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("sweeper")
+
+	if s.Interval <= 0 {
+		return fmt.Errorf("sweep interval must be positive: %s", s.Interval)
+	}
+
+	next := s.clock().NewTimer(0)
+	defer next.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-next.C():
+		}
+
+		timer := prometheus.NewTimer(sweepDuration)
+		summary, err := s.Sweep(ctx)
+		timer.ObserveDuration()
+		if err != nil {
+			// Log error but keep sweeping - failed deletions are retried in the next sweep
+			logger.Error(err, "Sweep failed")
+		}
+		logger.Info("Sweep finished", "namespaces", summary.Namespaces, "pods", summary.Pods,
+			"candidates", summary.Candidates, "deferred", summary.Deferred, "deleted", summary.Deleted, "failed", summary.Failed)
+
+		next.Reset(s.Interval)
+	}
+}
+
+// Sweep evaluates all pods once and deletes the expired ones.
+//
+// Failed deletions do not abort the sweep. They are counted and returned as a joined error.
+// If the namespaces or pods cannot be listed, the sweep is aborted with an error wrapping
+// ErrListFailed before any pod is deleted.
+func (s *PodSweeper) Sweep(ctx context.Context) (SweepSummary, error) {
+	var summary SweepSummary
+
+	var namespaces v1.NamespaceList
+	if err := s.List(ctx, &namespaces); err != nil {
+		return summary, fmt.Errorf("%w namespaces: %w", ErrListFailed, err)
+	}
+
+	now := s.clock().Now()
+
+	// Expired pods are only deleted while a maintenance window is open
+	windowOpen := s.Config.MaintenanceWindows().Open(now)
+
+	var expired []expiredPod
+	for _, namespace := range namespaces.Items {
+		if namespace.Name == excludedNamespace {
+			continue
+		}
+		if s.Shard != nil && !s.Shard.OwnsNamespace(namespace.Name) {
+			continue
+		}
+		summary.Namespaces++
+
+		var pods v1.PodList
+		if err := s.List(ctx, &pods, client.InNamespace(namespace.Name)); err != nil {
+			return summary, fmt.Errorf("%w pods of namespace %s: %w", ErrListFailed, namespace.Name, err)
+		}
+
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			summary.Pods++
+
+			decision, err := EvaluatePod(pod, s.Config, now)
+			if err != nil {
+				continue
+			}
+			recordDecision(decision)
+			if !decision.Candidate {
+				continue
+			}
+			summary.Candidates++
+
+			if !decision.Expired {
+				continue
+			}
+			if !windowOpen {
+				summary.Deferred++
+				continue
+			}
+			expired = append(expired, expiredPod{pod: pod, decision: decision})
+		}
+	}
+
+	summary.Expired = len(expired) + summary.Deferred
+
+	if s.DryRun {
+		logger := log.FromContext(ctx)
+		for _, e := range expired {
+			logger.Info("Would delete pod (dry run)", "pod", client.ObjectKeyFromObject(e.pod), "phase", e.pod.Status.Phase)
+			observeDeletion(ctx, s.Observers, e.pod, e.decision, true)
+		}
+		return summary, nil
+	}
+
+	if s.Config.Paused() {
+		log.FromContext(ctx).V(1).Info("Not deleting pods while paused", "expired", summary.Expired)
+		return summary, nil
+	}
+
+	deleted, errs, aborted := s.deleteAll(ctx, expired)
+	summary.Failed = len(errs)
+	summary.Deleted = deleted
+
+	return summary, errors.Join(append(errs, aborted)...)
+}
+
+// expiredPod is a pod which should be deleted and the decision leading to that.
+type expiredPod struct {
+	pod      *v1.Pod
+	decision PodDecision
+}
+
+// deleteAll deletes the given pods with at most Concurrency concurrent requests. It returns
+// the number of deleted pods, including the pods deleted together with their Job, and the
+// errors of all failed deletions. Once ctx is done, no further deletions are started and
+// the returned error reports the pods which have not been deleted.
+func (s *PodSweeper) deleteAll(ctx context.Context, pods []expiredPod) (int, []error, error) {
+	logger := log.FromContext(ctx)
+
+	concurrency := s.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		errs    []error
+		count   int
+		deleted = make(map[types.UID]bool) // pods deleted together with their Job
+	)
+
+	// handled reports whether pod has already been deleted together with its Job,
+	// which happens if other pods of the Job are expired as well
+	handled := func(pod *v1.Pod) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return deleted[pod.UID]
+	}
+
+	var aborted error
+
+	sem := make(chan struct{}, concurrency)
+	for i, e := range pods {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			aborted = fmt.Errorf("sweep aborted, %d expired pods not deleted: %w", len(pods)-i, ctx.Err())
+			break
+		}
+		wg.Add(1)
+
+		go func(e expiredPod) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			pod := e.pod
+			if handled(pod) {
+				return
+			}
+
+			object, pods, err := deleteExpiredPod(ctx, s.Client, s.PreDeleteHooks, e, s.Config, s.clock().Now(), s.DeleteFinishedJobs)
+			if apierrors.IsNotFound(err) {
+				// Deleted by someone else in the meantime, there is nothing to record
+				return
+			}
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("failed to delete pod %s/%s: %w", pod.Namespace, pod.Name, err))
+				mu.Unlock()
+				return
+			}
+
+			job, isJob := object.(*batchv1.Job)
+
+			mu.Lock()
+			if isJob {
+				for _, p := range pods {
+					deleted[p.pod.UID] = true
+				}
+			}
+			count += len(pods)
+			mu.Unlock()
+
+			for _, p := range pods {
+				observeDeletion(ctx, s.Observers, p.pod, p.decision, false)
+			}
+			if isJob {
+				logger.Info("Job deleted", "pod", client.ObjectKeyFromObject(pod), "job", job.Name, "pods", len(pods))
+			} else {
+				logger.Info("Pod deleted", "pod", client.ObjectKeyFromObject(pod), "phase", pod.Status.Phase)
+			}
+		}(e)
+	}
+
+	wg.Wait()
+
+	return count, errs, aborted
+}
+
+func (s *PodSweeper) clock() clock.Clock {
+	if s.Clock == nil {
+		return clock.RealClock{}
+	}
+	return s.Clock
+}
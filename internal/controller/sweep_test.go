@@ -0,0 +1,264 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	clocktesting "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func Test_PodSweeperSweep(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(now)
+
+	namespace := func(name string) *v1.Namespace {
+		return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	}
+	pod := func(namespace, name string, phase v1.PodPhase, age time.Duration) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, CreationTimestamp: metav1.NewTime(now.Add(-age))},
+			Status:     v1.PodStatus{Phase: phase},
+		}
+	}
+
+	objects := []client.Object{
+		namespace("a"),
+		namespace("b"),
+		namespace(excludedNamespace),
+		pod("a", "expired-failed", v1.PodFailed, 2*time.Hour),
+		pod("a", "expired-succeeded", v1.PodSucceeded, time.Hour),
+		pod("a", "young-failed", v1.PodFailed, time.Minute),
+		pod("a", "running", v1.PodRunning, 2*time.Hour),
+		pod("b", "expired-pending", v1.PodPending, 3*time.Hour),
+		pod(excludedNamespace, "expired-failed", v1.PodFailed, 2*time.Hour),
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build()
+	s := &PodSweeper{
+		Client:      c,
+		Config:      NewPodReconcilerConfig(),
+		Clock:       clk,
+		Interval:    time.Minute,
+		Concurrency: 2,
+	}
+
+	summary, err := s.Sweep(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, SweepSummary{Namespaces: 2, Pods: 5, Candidates: 4, Expired: 3, Deleted: 3}, summary)
+
+	var remaining v1.PodList
+	require.NoError(t, c.List(context.Background(), &remaining))
+
+	names := make([]string, 0, len(remaining.Items))
+	for _, p := range remaining.Items {
+		names = append(names, p.Namespace+"/"+p.Name)
+	}
+	require.ElementsMatch(t, []string{"a/young-failed", "a/running", excludedNamespace + "/expired-failed"}, names)
+}
+
+func Test_PodSweeperDryRun(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(now)
+
+	objects := []client.Object{
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "expired", CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour))},
+			Status:     v1.PodStatus{Phase: v1.PodFailed},
+		},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build()
+	s := &PodSweeper{
+		Client: c,
+		Config: NewPodReconcilerConfig(),
+		Clock:  clk,
+		DryRun: true,
+	}
+
+	summary, err := s.Sweep(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, SweepSummary{Namespaces: 1, Pods: 1, Candidates: 1, Expired: 1}, summary)
+
+	var remaining v1.PodList
+	require.NoError(t, c.List(context.Background(), &remaining))
+	require.Len(t, remaining.Items, 1, "dry run must not delete pods")
+}
+
+func Test_PodSweeperDefersToMaintenanceWindow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(now)
+
+	objects := []client.Object{
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "expired", CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour))},
+			Status:     v1.PodStatus{Phase: v1.PodFailed},
+		},
+	}
+
+	windows, err := ParseMaintenanceWindows("0 9 * * * 8h", "")
+	require.NoError(t, err)
+	config := NewPodReconcilerConfig()
+	config.SetMaintenanceWindows(windows)
+
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).Build()
+	s := &PodSweeper{Client: c, Config: config, Clock: clk}
+
+	summary, err := s.Sweep(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, SweepSummary{Namespaces: 1, Pods: 1, Candidates: 1, Expired: 1, Deferred: 1}, summary)
+
+	var remaining v1.PodList
+	require.NoError(t, c.List(context.Background(), &remaining))
+	require.Len(t, remaining.Items, 1, "pods must not be deleted outside of maintenance windows")
+
+	clk.Step(13 * time.Hour)
+	summary, err = s.Sweep(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, SweepSummary{Namespaces: 1, Pods: 1, Candidates: 1, Expired: 1, Deleted: 1}, summary)
+}
+
+func Test_PodSweeperDeletesFinishedJobsOnce(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(now)
+
+	pod := func(name string) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "default",
+				Name:              name,
+				UID:               types.UID(name + "-uid"),
+				CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour)),
+				Labels:            map[string]string{batchv1.ControllerUidLabel: "job-uid"},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "batch/v1", Kind: "Job", Name: "some-job", UID: "job-uid", Controller: ptr.To(true),
+				}},
+			},
+			Status: v1.PodStatus{Phase: v1.PodFailed},
+		}
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "some-job", UID: "job-uid"},
+		Status:     batchv1.JobStatus{Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: v1.ConditionTrue}}},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).
+		WithObjects(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}, pod("some-job-a"), pod("some-job-b"), job).Build()
+	observer := &recordingDeletionObserver{}
+	s := &PodSweeper{
+		Client:             c,
+		Config:             NewPodReconcilerConfig(),
+		Clock:              clk,
+		Interval:           time.Minute,
+		Concurrency:        1,
+		Observers:          []DeletionObserver{observer},
+		DeleteFinishedJobs: true,
+	}
+
+	summary, err := s.Sweep(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 2, summary.Deleted)
+	require.ElementsMatch(t, []string{"some-job-a", "some-job-b"}, observer.deleted)
+	require.True(t, apierrors.IsNotFound(c.Get(context.Background(), client.ObjectKeyFromObject(job), &batchv1.Job{})))
+}
+
+func Test_PodSweeperIgnoresPodsDeletedByOthers(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(now)
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "expired", CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour))},
+		Status:     v1.PodStatus{Phase: v1.PodFailed},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).
+		WithObjects(&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}, pod).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Delete: func(_ context.Context, _ client.WithWatch, obj client.Object, _ ...client.DeleteOption) error {
+				return apierrors.NewNotFound(v1.Resource("pods"), obj.GetName())
+			},
+		}).Build()
+	observer := &recordingDeletionObserver{}
+	s := &PodSweeper{
+		Client:    c,
+		Config:    NewPodReconcilerConfig(),
+		Clock:     clk,
+		Interval:  time.Minute,
+		Observers: []DeletionObserver{observer},
+	}
+
+	summary, err := s.Sweep(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, SweepSummary{Namespaces: 1, Pods: 1, Candidates: 1, Expired: 1}, summary)
+	require.Empty(t, observer.deleted)
+}
+
+func Test_PodSweeperStopsDeletingWhenContextIsDone(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(now)
+
+	objects := []client.Object{&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}}
+	for _, name := range []string{"a", "b", "c"} {
+		objects = append(objects, &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, CreationTimestamp: metav1.NewTime(now.Add(-2 * time.Hour))},
+			Status:     v1.PodStatus{Phase: v1.PodFailed},
+		})
+	}
+
+	// The context is cancelled during the first deletion, e.g. by a timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objects...).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+				cancel()
+				return c.Delete(ctx, obj, opts...)
+			},
+		}).Build()
+	s := &PodSweeper{
+		Client:      c,
+		Config:      NewPodReconcilerConfig(),
+		Clock:       clk,
+		Interval:    time.Minute,
+		Concurrency: 1,
+	}
+
+	summary, err := s.Sweep(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	require.ErrorContains(t, err, "2 expired pods not deleted")
+	require.Equal(t, 1, summary.Deleted)
+	require.Zero(t, summary.Failed)
+
+	var pods v1.PodList
+	require.NoError(t, c.List(context.Background(), &pods))
+	require.Len(t, pods.Items, 2)
+}
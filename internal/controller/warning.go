@@ -0,0 +1,123 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DeleteAfterAnnotation is set by PodReconciler on pods waiting to reach their max age.
+// Its value is the time of deletion in RFC3339 format.
+const DeleteAfterAnnotation = "podbouncer.io/delete-after"
+
+// Reason of the warning event emitted on pods before they are deleted.
+const reasonDeletionPending = "DeletionPending"
+
+// annotateDeleteAfter sets DeleteAfterAnnotation on pod to the deadline of decision.
+func (r *PodReconciler) annotateDeleteAfter(ctx context.Context, pod *v1.Pod, decision PodDecision) error {
+	value := decision.ExpiresAt.UTC().Format(time.RFC3339)
+	if !r.AnnotateDeleteAfter || pod.Annotations[DeleteAfterAnnotation] == value {
+		return nil
+	}
+
+	patch := client.MergeFrom(pod.DeepCopy())
+	metav1.SetMetaDataAnnotation(&pod.ObjectMeta, DeleteAfterAnnotation, value)
+	return client.IgnoreNotFound(r.Patch(ctx, pod, patch))
+}
+
+// removeDeleteAfter removes DeleteAfterAnnotation from pods which are no longer
+// waiting to be deleted, e.g. because they have been annotated to be kept.
+func (r *PodReconciler) removeDeleteAfter(ctx context.Context, pod *v1.Pod) error {
+	if _, found := pod.Annotations[DeleteAfterAnnotation]; !r.AnnotateDeleteAfter || !found {
+		return nil
+	}
+
+	patch := client.MergeFrom(pod.DeepCopy())
+	delete(pod.Annotations, DeleteAfterAnnotation)
+	return client.IgnoreNotFound(r.Patch(ctx, pod, patch))
+}
+
+// warnPendingDeletion emits a warning event on pod once it is within WarningLeadTime
+// of its deadline. Until then, Warnings triggers reconciliation when the warning is due.
+func (r *PodReconciler) warnPendingDeletion(pod *v1.Pod, decision PodDecision) {
+	if r.WarningLeadTime <= 0 {
+		return
+	}
+
+	key := client.ObjectKeyFromObject(pod)
+	warnAt := decision.ExpiresAt.Add(-r.WarningLeadTime)
+	if r.Clock.Now().Before(warnAt) {
+		r.Warnings.Schedule(key, warnAt)
+		return
+	}
+
+	if !r.warned.add(key, decision.ExpiresAt) {
+		return
+	}
+
+	r.Recorder.Eventf(pod, v1.EventTypeWarning, reasonDeletionPending,
+		"Pod will be deleted by podbouncer at %s, annotate it with %s=true to keep it",
+		decision.ExpiresAt.UTC().Format(time.RFC3339), KeepAnnotation)
+}
+
+// forgetPod stops tracking the given pod.
+func (r *PodReconciler) forgetPod(key types.NamespacedName) {
+	r.Expiry.Remove(key)
+	if r.Warnings != nil {
+		r.Warnings.Remove(key)
+	}
+	r.warned.remove(key)
+	r.stuck.remove(key)
+}
+
+// warningTracker remembers the deadlines pods have been warned about, so every
+// deadline is only announced once. The zero value is ready to use.
+type warningTracker struct {
+	mu        sync.Mutex
+	deadlines map[types.NamespacedName]time.Time
+}
+
+// add records a warning about deadline. It returns false if the pod has already
+// been warned about the same deadline.
+func (t *warningTracker) add(key types.NamespacedName, deadline time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if warned, found := t.deadlines[key]; found && warned.Equal(deadline) {
+		return false
+	}
+
+	if t.deadlines == nil {
+		t.deadlines = make(map[types.NamespacedName]time.Time)
+	}
+	t.deadlines[key] = deadline
+	return true
+}
+
+func (t *warningTracker) remove(key types.NamespacedName) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.deadlines, key)
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	clocktesting "k8s.io/utils/clock/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_PodReconcilerWarnsBeforeDeletion(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clk := clocktesting.NewFakeClock(now)
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "default",
+			Name:              "some-pod",
+			CreationTimestamp: metav1.NewTime(now.Add(-30 * time.Minute)),
+		},
+		Status: v1.PodStatus{Phase: v1.PodSucceeded},
+	}
+	key := types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name}
+	req := ctrl.Request{NamespacedName: key}
+
+	recorder := record.NewFakeRecorder(10)
+	r := &PodReconciler{
+		Client:              fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(pod).Build(),
+		Scheme:              scheme.Scheme,
+		Config:              NewPodReconcilerConfig(),
+		Clock:               clk,
+		Expiry:              NewExpiryScheduler(clk),
+		Warnings:            NewExpiryScheduler(clk),
+		AnnotateDeleteAfter: true,
+		WarningLeadTime:     10 * time.Minute,
+		Recorder:            recorder,
+	}
+
+	get := func() *v1.Pod {
+		var current v1.Pod
+		require.NoError(t, r.Get(context.Background(), key, &current))
+		return &current
+	}
+
+	// Candidates are annotated with their deadline and wait for the warning period
+	_, err := r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, "2024-01-01T12:30:00Z", get().Annotations[DeleteAfterAnnotation])
+	remaining, found := r.Warnings.Remaining(key)
+	require.True(t, found)
+	require.Equal(t, 20*time.Minute, remaining)
+	require.Empty(t, recorder.Events)
+
+	// Within the warning period, the warning is emitted exactly once
+	clk.Step(25 * time.Minute)
+	for i := 0; i < 2; i++ {
+		_, err = r.Reconcile(context.Background(), req)
+		require.NoError(t, err)
+	}
+	require.Len(t, recorder.Events, 1)
+	require.Equal(t, "Warning DeletionPending Pod will be deleted by podbouncer at 2024-01-01T12:30:00Z, "+
+		"annotate it with podbouncer.io/keep=true to keep it", <-recorder.Events)
+
+	// Kept pods are no longer tracked and their annotation is removed
+	kept := get()
+	kept.Annotations[KeepAnnotation] = "true"
+	require.NoError(t, r.Update(context.Background(), kept))
+
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.NotContains(t, get().Annotations, DeleteAfterAnnotation)
+	_, found = r.Expiry.Remaining(key)
+	require.False(t, found)
+
+	clk.Step(time.Hour)
+	_, err = r.Reconcile(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, "true", get().Annotations[KeepAnnotation], "kept pods should not be deleted")
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	queuedNotifications = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "podbouncer_notifications_queued",
+		Help: "Number of deletions waiting to be sent to the notification endpoints.",
+	})
+
+	droppedNotificationsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "podbouncer_notifications_dropped_total",
+		Help: "Number of deletions dropped because the notification queue was full.",
+	})
+
+	sentNotificationsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "podbouncer_notifications_sent_total",
+		Help: "Number of notification requests delivered to an endpoint.",
+	})
+
+	failedNotificationsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "podbouncer_notifications_failed_total",
+		Help: "Number of notification requests which could not be delivered after all attempts.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		queuedNotifications,
+		droppedNotificationsTotal,
+		sentNotificationsTotal,
+		failedNotificationsTotal,
+	)
+}
@@ -0,0 +1,274 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package notify sends batched notifications about deleted pods to HTTP endpoints.
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/fabiante/podbouncer/internal/controller"
+	"github.com/fabiante/podbouncer/internal/webhook"
+)
+
+// shutdownGracePeriod is the time queued deletions are still delivered after Start returns.
+const shutdownGracePeriod = 5 * time.Second
+
+// SignatureHeader contains the hex encoded HMAC-SHA256 of the request body, prefixed
+// with "sha256=". It is only set if a secret is configured.
+const SignatureHeader = "X-Podbouncer-Signature"
+
+// Deletion describes a deleted pod.
+type Deletion struct {
+	Time time.Time `json:"time"`
+	controller.PodRecord
+}
+
+// Payload is the body of a notification request.
+type Payload struct {
+	Deletions []Deletion `json:"deletions"`
+}
+
+// Options configure a Notifier.
+type Options struct {
+	// Endpoints receive every batch of deletions.
+	Endpoints []string
+
+	// Secret is used to sign requests. Requests are not signed if it is empty.
+	Secret []byte
+
+	// BatchSize is the maximum number of deletions sent in a single request.
+	BatchSize int
+
+	// FlushInterval is the maximum time a deletion waits for its batch to be sent.
+	FlushInterval time.Duration
+
+	// QueueSize is the maximum number of deletions waiting to be sent. Deletions are
+	// dropped while the queue is full.
+	QueueSize int
+
+	// MaxAttempts is the number of attempts to deliver a batch to an endpoint.
+	MaxAttempts int
+
+	// RetryDelay is the delay before the second attempt. It doubles with every attempt.
+	RetryDelay time.Duration
+
+	// Client is used to send requests. http.DefaultClient is used if it is nil.
+	Client *http.Client
+}
+
+// DefaultOptions returns the default options without any endpoints.
+func DefaultOptions() Options {
+	return Options{
+		BatchSize:     100,
+		FlushInterval: 5 * time.Second,
+		QueueSize:     1000,
+		MaxAttempts:   5,
+		RetryDelay:    time.Second,
+	}
+}
+
+// Notifier sends batches of deleted pods to HTTP endpoints.
+//
+// It implements controller.DeletionObserver and queues deletions without blocking.
+// Deletions in dry run mode are ignored. Use NewNotifier to create instances.
+type Notifier struct {
+	options Options
+	clock   clock.Clock
+	poster  *webhook.Poster
+	queue   chan Deletion
+}
+
+var _ controller.DeletionObserver = &Notifier{}
+
+func NewNotifier(o Options, clk clock.Clock) *Notifier {
+	if o.BatchSize < 1 {
+		o.BatchSize = 1
+	}
+
+	return &Notifier{
+		options: o,
+		clock:   clk,
+		poster:  &webhook.Poster{Client: o.Client, Clock: clk, MaxAttempts: o.MaxAttempts, RetryDelay: o.RetryDelay},
+		queue:   make(chan Deletion, o.QueueSize),
+	}
+}
+
+// ObserveDeletion implements controller.DeletionObserver.
+func (n *Notifier) ObserveDeletion(ctx context.Context, pod *v1.Pod, decision controller.PodDecision, dryRun bool) {
+	if dryRun {
+		return
+	}
+
+	d := Deletion{Time: n.clock.Now().UTC(), PodRecord: controller.NewPodRecord(pod, decision)}
+
+	select {
+	case n.queue <- d:
+		queuedNotifications.Set(float64(len(n.queue)))
+	default:
+		droppedNotificationsTotal.Inc()
+		log.FromContext(ctx).Info("Notification queue is full, dropping deletion", "pod", types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name})
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Notifications are sent by
+// every replica which deletes pods.
+func (n *Notifier) NeedLeaderElection() bool {
+	return false
+}
+
+// Start sends batches of queued deletions until ctx is done.
+//
+// Every endpoint is served by its own goroutine with its own queue of batches, so an
+// endpoint which is down and retried does not delay the delivery to the others.
+func (n *Notifier) Start(ctx context.Context) error {
+	logger := log.FromContext(ctx).WithName("notifier")
+	ctx = log.IntoContext(ctx, logger)
+
+	// Deliveries outlive ctx by a grace period to send what has been queued so far
+	deliverCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+
+	var wg sync.WaitGroup
+	queues := make([]chan request, len(n.options.Endpoints))
+	for i, endpoint := range n.options.Endpoints {
+		queues[i] = make(chan request, max(1, n.options.QueueSize/n.options.BatchSize))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n.deliver(deliverCtx, endpoint, queues[i])
+		}()
+	}
+
+	var batch []Deletion
+	var flush <-chan time.Time
+	var timer clock.Timer
+
+	send := func() {
+		if timer != nil {
+			timer.Stop()
+			timer, flush = nil, nil
+		}
+		n.enqueue(ctx, queues, batch)
+		batch = nil
+	}
+
+	for {
+		select {
+		case d := <-n.queue:
+			queuedNotifications.Set(float64(len(n.queue)))
+			batch = append(batch, d)
+			if len(batch) >= n.options.BatchSize {
+				send()
+			} else if timer == nil {
+				timer = n.clock.NewTimer(n.options.FlushInterval)
+				flush = timer.C()
+			}
+		case <-flush:
+			timer, flush = nil, nil
+			send()
+		case <-ctx.Done():
+			// Deliver what has been queued so far within a grace period
+			for len(n.queue) > 0 {
+				batch = append(batch, <-n.queue)
+			}
+			if len(batch) > 0 {
+				send()
+			}
+			for _, q := range queues {
+				close(q)
+			}
+
+			grace := time.AfterFunc(shutdownGracePeriod, cancel)
+			defer grace.Stop()
+			wg.Wait()
+			return nil
+		}
+	}
+}
+
+// request is a batch of deletions encoded for delivery.
+type request struct {
+	header    http.Header
+	body      []byte
+	deletions int
+}
+
+// enqueue encodes deletions in batches of at most BatchSize and queues them for every
+// endpoint. Batches are dropped for endpoints whose queue is full.
+func (n *Notifier) enqueue(ctx context.Context, queues []chan request, deletions []Deletion) {
+	logger := log.FromContext(ctx)
+
+	for len(deletions) > 0 {
+		size := min(len(deletions), n.options.BatchSize)
+
+		body, err := json.Marshal(Payload{Deletions: deletions[:size]})
+		if err != nil {
+			logger.Error(err, "Failed to encode notification")
+			return
+		}
+
+		header := http.Header{"Content-Type": {"application/json"}}
+		if len(n.options.Secret) > 0 {
+			header.Set(SignatureHeader, Sign(n.options.Secret, body))
+		}
+
+		for i, q := range queues {
+			select {
+			case q <- request{header: header, body: body, deletions: size}:
+			default:
+				droppedNotificationsTotal.Add(float64(size))
+				logger.Info("Notification queue of endpoint is full, dropping deletions",
+					"endpoint", n.options.Endpoints[i], "deletions", size)
+			}
+		}
+
+		deletions = deletions[size:]
+	}
+}
+
+// deliver sends the requests of queue to endpoint until queue is closed.
+func (n *Notifier) deliver(ctx context.Context, endpoint string, queue <-chan request) {
+	logger := log.FromContext(ctx)
+
+	for req := range queue {
+		if err := n.poster.Post(ctx, endpoint, req.header, req.body); err != nil {
+			failedNotificationsTotal.Inc()
+			logger.Error(err, "Failed to send notification", "endpoint", endpoint, "deletions", req.deletions)
+			continue
+		}
+		sentNotificationsTotal.Inc()
+	}
+}
+
+// Sign returns the value of the SignatureHeader for body.
+func Sign(secret, body []byte) string {
+	h := hmac.New(sha256.New, secret)
+	h.Write(body)
+	return "sha256=" + hex.EncodeToString(h.Sum(nil))
+}
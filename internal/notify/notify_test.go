@@ -0,0 +1,204 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+
+	"github.com/fabiante/podbouncer/internal/controller"
+)
+
+// endpoint records the payloads it receives. The first failures requests are answered
+// with 503 Service Unavailable.
+type endpoint struct {
+	secret   []byte
+	failures int
+
+	mu       sync.Mutex
+	requests int
+	payloads []Payload
+	invalid  int
+}
+
+func (e *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.requests++
+	if e.requests <= e.failures {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	if len(e.secret) > 0 && r.Header.Get(SignatureHeader) != Sign(e.secret, body) {
+		e.invalid++
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	var p Payload
+	if err := json.Unmarshal(body, &p); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	e.payloads = append(e.payloads, p)
+}
+
+func (e *endpoint) received() []Payload {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Payload(nil), e.payloads...)
+}
+
+func newPod(name string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, UID: types.UID("uid-" + name)},
+		Status:     v1.PodStatus{Phase: v1.PodFailed},
+	}
+}
+
+func testOptions(urls ...string) Options {
+	o := DefaultOptions()
+	o.Endpoints = urls
+	o.FlushInterval = 20 * time.Millisecond
+	o.RetryDelay = time.Millisecond
+	return o
+}
+
+func Test_NotifierBatches(t *testing.T) {
+	secret := []byte("secret")
+	e1, e2 := &endpoint{secret: secret}, &endpoint{secret: secret}
+	s1, s2 := httptest.NewServer(e1), httptest.NewServer(e2)
+	defer s1.Close()
+	defer s2.Close()
+
+	o := testOptions(s1.URL, s2.URL)
+	o.Secret = secret
+	o.BatchSize = 2
+	n := NewNotifier(o, clock.RealClock{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = n.Start(ctx) }()
+
+	decision := controller.PodDecision{Rule: "phase=Failed", Age: time.Hour}
+	n.ObserveDeletion(ctx, newPod("a"), decision, false)
+	n.ObserveDeletion(ctx, newPod("b"), decision, false)
+	n.ObserveDeletion(ctx, newPod("dry-run"), decision, true)
+	n.ObserveDeletion(ctx, newPod("c"), decision, false)
+
+	for _, e := range []*endpoint{e1, e2} {
+		require.Eventually(t, func() bool { return len(e.received()) == 2 }, 5*time.Second, 10*time.Millisecond)
+
+		payloads := e.received()
+		require.Len(t, payloads[0].Deletions, 2, "a full batch should be sent immediately")
+		require.Equal(t, "a", payloads[0].Deletions[0].Name)
+		require.Equal(t, "1h0m0s", payloads[0].Deletions[0].Age)
+		require.Equal(t, "phase=Failed", payloads[0].Deletions[0].Rule)
+		require.Len(t, payloads[1].Deletions, 1, "a partial batch should be sent after the flush interval")
+		require.Equal(t, "c", payloads[1].Deletions[0].Name)
+		require.Zero(t, e.invalid)
+	}
+}
+
+func Test_NotifierRetries(t *testing.T) {
+	e := &endpoint{failures: 2}
+	s := httptest.NewServer(e)
+	defer s.Close()
+
+	n := NewNotifier(testOptions(s.URL), clock.RealClock{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = n.Start(ctx) }()
+
+	n.ObserveDeletion(ctx, newPod("a"), controller.PodDecision{}, false)
+
+	require.Eventually(t, func() bool { return len(e.received()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 3, e.requests)
+}
+
+func Test_NotifierDeliversToEndpointsIndependently(t *testing.T) {
+	dead, healthy := &endpoint{failures: 1000}, &endpoint{}
+	s1, s2 := httptest.NewServer(dead), httptest.NewServer(healthy)
+	defer s1.Close()
+	defer s2.Close()
+
+	// The dead endpoint waits for its retry until the test ends
+	o := testOptions(s1.URL, s2.URL)
+	o.BatchSize = 1
+	o.RetryDelay = time.Hour
+	n := NewNotifier(o, clock.RealClock{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = n.Start(ctx) }()
+
+	n.ObserveDeletion(ctx, newPod("a"), controller.PodDecision{}, false)
+	n.ObserveDeletion(ctx, newPod("b"), controller.PodDecision{}, false)
+
+	require.Eventually(t, func() bool { return len(healthy.received()) == 2 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func Test_NotifierQueueFull(t *testing.T) {
+	o := testOptions()
+	o.QueueSize = 2
+	n := NewNotifier(o, clock.RealClock{})
+
+	// Without a running notifier, the queue is never drained
+	for _, name := range []string{"a", "b", "c"} {
+		n.ObserveDeletion(context.Background(), newPod(name), controller.PodDecision{}, false)
+	}
+
+	require.Len(t, n.queue, 2)
+}
+
+func Test_NotifierFlushesOnShutdown(t *testing.T) {
+	e := &endpoint{}
+	s := httptest.NewServer(e)
+	defer s.Close()
+
+	o := testOptions(s.URL)
+	o.FlushInterval = time.Hour
+	n := NewNotifier(o, clock.RealClock{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n.ObserveDeletion(ctx, newPod("a"), controller.PodDecision{}, false)
+	cancel()
+
+	require.NoError(t, n.Start(ctx))
+	require.Len(t, e.received(), 1)
+}
+
+func Test_Sign(t *testing.T) {
+	require.Equal(t, "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+		Sign([]byte("key"), []byte("The quick brown fox jumps over the lazy dog")))
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhook posts payloads to HTTP endpoints and retries failed requests.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"k8s.io/utils/clock"
+)
+
+// Poster posts request bodies to HTTP endpoints.
+//
+// Failed requests are retried with exponential backoff on network errors, 429 and
+// 5xx responses. Any other response outside of 2xx fails immediately.
+type Poster struct {
+	// Client is used to send requests. http.DefaultClient is used if it is nil.
+	Client *http.Client
+
+	// Clock is used to wait between attempts. The real clock is used if it is nil.
+	Clock clock.Clock
+
+	// MaxAttempts is the number of attempts to deliver a body. Values below 1 are treated as 1.
+	MaxAttempts int
+
+	// RetryDelay is the delay before the second attempt. It doubles with every attempt.
+	RetryDelay time.Duration
+}
+
+// Post sends body with the given headers to endpoint until it is accepted, it fails
+// permanently, MaxAttempts are exhausted or ctx is done.
+func (p *Poster) Post(ctx context.Context, endpoint string, header http.Header, body []byte) error {
+	clk := p.Clock
+	if clk == nil {
+		clk = clock.RealClock{}
+	}
+
+	delay := p.RetryDelay
+	for attempt := 1; ; attempt++ {
+		retry, err := p.post(ctx, endpoint, header, body)
+		if err == nil || !retry || attempt >= p.MaxAttempts {
+			return err
+		}
+
+		select {
+		case <-clk.After(delay):
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		}
+		delay *= 2
+	}
+}
+
+// post sends a single request. The returned bool reports whether a failed request
+// should be retried.
+func (p *Poster) post(ctx context.Context, endpoint string, header http.Header, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	c := p.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("unexpected response: %s", resp.Status)
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PosterPost(t *testing.T) {
+	type Test struct {
+		Name         string
+		Statuses     []int
+		ExpectError  bool
+		ExpectedSent int32
+	}
+
+	tests := []Test{
+		{Name: "accepted", Statuses: []int{http.StatusAccepted}, ExpectedSent: 1},
+		{Name: "retries 5xx and 429", Statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}, ExpectedSent: 3},
+		{Name: "does not retry 4xx", Statuses: []int{http.StatusBadRequest, http.StatusOK}, ExpectError: true, ExpectedSent: 1},
+		{Name: "gives up after max attempts", Statuses: []int{500, 500, 500, 500}, ExpectError: true, ExpectedSent: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			var sent atomic.Int32
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				require.Equal(t, "payload", string(body))
+				require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+				i := sent.Add(1) - 1
+				w.WriteHeader(test.Statuses[i])
+			}))
+			defer s.Close()
+
+			p := &Poster{MaxAttempts: 3, RetryDelay: time.Millisecond}
+			err := p.Post(context.Background(), s.URL, http.Header{"Content-Type": {"application/json"}}, []byte("payload"))
+			if test.ExpectError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, test.ExpectedSent, sent.Load())
+		})
+	}
+}
+
+func Test_PosterStopsRetryingWhenCanceled(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	p := &Poster{MaxAttempts: 10, RetryDelay: time.Hour}
+	err := p.Post(ctx, s.URL, nil, nil)
+	require.ErrorIs(t, err, context.Canceled)
+}